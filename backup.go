@@ -1,10 +1,13 @@
 package onapp
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 type Backups []Backup
@@ -41,7 +44,11 @@ type Backup struct {
 }
 
 func (c *Client) GetVirtualMachineBackups(vmId int) (Backups, error) {
-	data, err, _ := c.getReq("/virtual_machines/", strconv.Itoa(vmId), "/backups.json")
+	return c.GetVirtualMachineBackupsContext(context.Background(), vmId)
+}
+
+func (c *Client) GetVirtualMachineBackupsContext(ctx context.Context, vmId int) (Backups, error) {
+	data, err, _ := c.getReq(ctx, "virtual_machines/", strconv.Itoa(vmId), "/backups.json")
 	if err != nil {
 		return Backups{}, err
 	}
@@ -59,7 +66,11 @@ func (c *Client) GetVirtualMachineBackups(vmId int) (Backups, error) {
 }
 
 func (c *Client) DeleteVirtualMachineBackup(id int) error {
-	_, err, st := c.deleteReq("/backups/", strconv.Itoa(id), ".json")
+	return c.DeleteVirtualMachineBackupContext(context.Background(), id)
+}
+
+func (c *Client) DeleteVirtualMachineBackupContext(ctx context.Context, id int) error {
+	_, err, st := c.deleteReq(ctx, "backups/", strconv.Itoa(id), ".json")
 	if err != nil {
 		return err
 	}
@@ -68,3 +79,83 @@ func (c *Client) DeleteVirtualMachineBackup(id int) error {
 	}
 	return nil
 }
+
+// Takes a backup of a virtual machine's disk, returning the backup along with its transaction.
+// The transaction may not be valid (see Transaction.IsValid) if the dashboard hadn't queued it yet.
+func (c *Client) CreateDiskBackup(vmId, diskId int, note string) (Backup, Transaction, error) {
+	return c.CreateDiskBackupContext(context.Background(), vmId, diskId, note)
+}
+
+func (c *Client) CreateDiskBackupContext(ctx context.Context, vmId, diskId int, note string) (Backup, Transaction, error) {
+	body, err := json.Marshal(map[string]map[string]string{"backup": {"note": note}})
+	if err != nil {
+		return Backup{}, Transaction{}, err
+	}
+	mark := c.markVmTransactions(ctx, vmId)
+	data, err, _ := c.postReq(ctx, string(body),
+		"virtual_machines/", strconv.Itoa(vmId), "/disks/", strconv.Itoa(diskId), "/backups.json")
+	if err != nil {
+		return Backup{}, Transaction{}, err
+	}
+	var out map[string]Backup
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return Backup{}, Transaction{}, err
+	}
+	tx, err := c.findVmTransaction(ctx, vmId, "take_backup", mark, data)
+	return out["backup"], tx, err
+}
+
+// Restores a backup over the disk it was taken from. The virtual machine is shut down to do so.
+func (c *Client) RestoreBackup(vmId, backupId int) (Transaction, error) {
+	return c.RestoreBackupContext(context.Background(), vmId, backupId)
+}
+
+func (c *Client) RestoreBackupContext(ctx context.Context, vmId, backupId int) (Transaction, error) {
+	mark := c.markVmTransactions(ctx, vmId)
+	data, err, _ := c.postReq(ctx, "",
+		"virtual_machines/", strconv.Itoa(vmId), "/backups/", strconv.Itoa(backupId), "/restore.json")
+	if err != nil {
+		return Transaction{}, err
+	}
+	return c.findVmTransaction(ctx, vmId, "restore_backup", mark, data)
+}
+
+// Creates a template with the given label from a backup of a virtual machine's primary disk
+func (c *Client) ConvertBackupToTemplate(vmId, backupId int, label string) (Transaction, error) {
+	return c.ConvertBackupToTemplateContext(context.Background(), vmId, backupId, label)
+}
+
+func (c *Client) ConvertBackupToTemplateContext(ctx context.Context, vmId, backupId int, label string) (Transaction, error) {
+	if label == "" {
+		return Transaction{}, errors.New("A label is required for the template")
+	}
+	body, err := json.Marshal(map[string]map[string]string{"backup": {"label": label}})
+	if err != nil {
+		return Transaction{}, err
+	}
+	mark := c.markVmTransactions(ctx, vmId)
+	data, err, _ := c.postReq(ctx, string(body), "backups/", strconv.Itoa(backupId), "/convert.json")
+	if err != nil {
+		return Transaction{}, err
+	}
+	return c.findVmTransaction(ctx, vmId, "convert_backup", mark, data)
+}
+
+// Replaces the note on a backup
+func (c *Client) UpdateBackupNote(backupId int, note string) error {
+	return c.UpdateBackupNoteContext(context.Background(), backupId, note)
+}
+
+func (c *Client) UpdateBackupNoteContext(ctx context.Context, backupId int, note string) error {
+	body, err := json.Marshal(map[string]map[string]string{"backup": {"note": note}})
+	if err != nil {
+		return err
+	}
+	_, err, _ = c.putReq(ctx, string(body), "backups/", strconv.Itoa(backupId), ".json")
+	return err
+}
+
+func (b *Backup) CreatedAtTime() (time.Time, error) {
+	return time.Parse(time.RFC3339, b.CreatedAt)
+}
@@ -0,0 +1,109 @@
+package onapp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetVirtualMachineBackups(t *testing.T) {
+	ts, _ := newAPIServer(t, map[string]string{
+		"GET /virtual_machines/7/backups.json": `[{"backup":{"id":3,"built":true,"disk_id":2,"note":"before upgrade",` +
+			`"created_at":"2015-03-02T12:00:00+00:00"}}]`,
+		"DELETE /backups/3.json": `204 `,
+	})
+	defer ts.Close()
+	c := newAPIClient(ts)
+
+	backups, err := c.GetVirtualMachineBackups(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 || backups[0].DiskID != 2 || backups[0].Note != "before upgrade" {
+		t.Fatalf("Unexpected backups: %+v", backups)
+	}
+	if created, err := backups[0].CreatedAtTime(); err != nil || !created.Equal(time.Date(2015, 3, 2, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected created time: %v %v", created, err)
+	}
+	if err := c.DeleteVirtualMachineBackup(3); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateDiskBackup(t *testing.T) {
+	ts, reqs := newAPIServer(t, map[string]string{
+		"GET /virtual_machines/7/transactions.json":     queuedTransactions("take_backup"),
+		"POST /virtual_machines/7/disks/2/backups.json": `201 {"backup":{"id":3,"note":"nightly"}}`,
+	})
+	defer ts.Close()
+	c := newAPIClient(ts)
+
+	backup, tx, err := c.CreateDiskBackup(7, 2, "nightly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backup.ID != 3 || tx.Id != 12 {
+		t.Errorf("Unexpected backup #%d, transaction #%d", backup.ID, tx.Id)
+	}
+	body, _ := (*reqs)[1].Body["backup"].(map[string]interface{})
+	if body["note"] != "nightly" {
+		t.Errorf("Unexpected body: %v", (*reqs)[1].Body)
+	}
+}
+
+func TestRestoreBackupNotQueued(t *testing.T) {
+	// Only the old take_backup and restore_backup are there after the request
+	ts, _ := newAPIServer(t, map[string]string{
+		"GET /virtual_machines/7/transactions.json":       `[{"transaction":{"id":10,"action":"restore_backup"}}]`,
+		"POST /virtual_machines/7/backups/3/restore.json": `201 `,
+	})
+	defer ts.Close()
+
+	tx, err := newAPIClient(ts).RestoreBackup(7, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.IsValid() {
+		t.Errorf("Expected no transaction, got the stale #%d", tx.Id)
+	}
+}
+
+func TestConvertBackupToTemplate(t *testing.T) {
+	ts, reqs := newAPIServer(t, map[string]string{
+		"GET /virtual_machines/7/transactions.json": `[]`,
+		"POST /backups/3/convert.json":              `201 {"transaction_id":20}`,
+	})
+	defer ts.Close()
+	c := newAPIClient(ts)
+
+	if _, err := c.ConvertBackupToTemplate(7, 3, ""); err == nil {
+		t.Error("Expected a blank label to be rejected")
+	}
+	tx, err := c.ConvertBackupToTemplate(7, 3, "Base image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Id != 20 {
+		t.Errorf("Expected the transaction from the response, got #%d", tx.Id)
+	}
+	// Only the mark was taken, the response had the transaction
+	if len(*reqs) != 2 {
+		t.Errorf("Expected 2 requests, got %d", len(*reqs))
+	}
+	body, _ := (*reqs)[1].Body["backup"].(map[string]interface{})
+	if body["label"] != "Base image" {
+		t.Errorf("Unexpected body: %v", (*reqs)[1].Body)
+	}
+}
+
+func TestVmTransactionMarkByTime(t *testing.T) {
+	mark := vmTransactionMark{at: time.Date(2015, 3, 2, 12, 0, 0, 0, time.UTC)}
+	if mark.precedes(Transaction{Id: 1, CreatedAt: "2015-03-02T11:59:59Z"}) {
+		t.Error("A transaction created before the mark was accepted")
+	}
+	if !mark.precedes(Transaction{Id: 1, CreatedAt: "2015-03-02T12:00:00Z"}) {
+		t.Error("A transaction created at the mark was rejected")
+	}
+	if mark.precedes(Transaction{Id: 1}) {
+		t.Error("A transaction without a creation time was accepted")
+	}
+}
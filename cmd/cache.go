@@ -3,17 +3,29 @@ package cmd
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/alexzorin/onapp"
+	"github.com/alexzorin/onapp/log"
 	"io/ioutil"
 	"os"
 	"os/user"
 	"path/filepath"
+	"time"
 )
 
 const (
-	cacheFileName = ".onapp_cache"
+	cacheFileName       = ".onapp_cache"
+	hvLabelsCacheName   = "hv"
+	userLabelsCacheName = "user"
 )
 
+// How long an id that the API didn't have a label for (e.g. a deleted user) is
+// remembered, so that it doesn't cause the labels to be fetched every time
+const labelMissTTL = 24 * time.Hour
+
+// Every label cache, so that Clear can remove them
+var labelCacheNames = []string{hvLabelsCacheName, userLabelsCacheName}
+
 var (
 	ErrCacheDoesntExist = errors.New("Cache doesn't exist")
 )
@@ -22,28 +34,26 @@ type Cache interface {
 	GetVirtualMachines() (onapp.VirtualMachines, error)
 	Clear()
 	Store(onapp.VirtualMachines) error
+	GetLabels(name string) (labelCache, error)
+	StoreLabels(name string, labels labelCache) error
+}
+
+// Labels of things looked up by id (e.g. hypervisors), when ids without a label were
+// last looked up, and whether the API user was forbidden from listing them so that
+// they aren't asked for again
+type labelCache struct {
+	Labels    map[int]string
+	Missing   map[int]time.Time
+	Forbidden bool
 }
 
 type fileBackedCache struct {
 }
 
 func (c *fileBackedCache) GetVirtualMachines() (onapp.VirtualMachines, error) {
-	p, err := c.getCacheFile(false)
-	if err != nil {
-		return nil, ErrCacheDoesntExist
-	}
-	cf, err := os.Open(p)
-	if err != nil {
-		return nil, errors.New("Cache unopenable - " + err.Error())
-	}
-	defer cf.Close()
-	data, err := ioutil.ReadAll(cf)
-	if err != nil {
-		return nil, errors.New("Couldn't read the cache file - " + err.Error())
-	}
 	var out onapp.VirtualMachines
-	if err := json.Unmarshal(data, &out); err != nil {
-		return nil, errors.New("Couldn't parse the cache file - " + err.Error())
+	if err := c.read(cacheFileName, &out); err != nil {
+		return nil, err
 	}
 	return out, nil
 }
@@ -55,11 +65,58 @@ func (c *fileBackedCache) Store(vms onapp.VirtualMachines) error {
 		vm.VncPassword = ""
 		vm.RootPassword = ""
 	}
-	p, err := c.getCacheFile(true)
+	return c.write(cacheFileName, vms)
+}
+
+func (c *fileBackedCache) GetLabels(name string) (labelCache, error) {
+	var out labelCache
+	if err := c.read(labelCacheFileName(name), &out); err != nil {
+		return labelCache{}, err
+	}
+	return out, nil
+}
+
+func (c *fileBackedCache) StoreLabels(name string, labels labelCache) error {
+	return c.write(labelCacheFileName(name), labels)
+}
+
+func labelCacheFileName(name string) string {
+	return ".onapp_" + name + "_labels_cache"
+}
+
+func (c *fileBackedCache) Clear() {
+	c.getCacheFile(cacheFileName, true)
+	for _, name := range labelCacheNames {
+		c.getCacheFile(labelCacheFileName(name), true)
+	}
+}
+
+func (c *fileBackedCache) read(name string, out interface{}) error {
+	p, err := c.getCacheFile(name, false)
+	if err != nil {
+		return ErrCacheDoesntExist
+	}
+	cf, err := os.Open(p)
+	if err != nil {
+		return errors.New("Cache unopenable - " + err.Error())
+	}
+	defer cf.Close()
+	data, err := ioutil.ReadAll(cf)
+	if err != nil {
+		return errors.New("Couldn't read the cache file - " + err.Error())
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return errors.New("Couldn't parse the cache file - " + err.Error())
+	}
+	return nil
+}
+
+func (c *fileBackedCache) write(name string, in interface{}) error {
+	p, err := c.getCacheFile(name, true)
 	if err != nil {
 		return err
 	}
-	data, err := json.Marshal(vms)
+	data, err := json.Marshal(in)
 	if err != nil {
 		return err
 	}
@@ -69,17 +126,12 @@ func (c *fileBackedCache) Store(vms onapp.VirtualMachines) error {
 	return nil
 }
 
-func (c *fileBackedCache) Clear() {
-	c.getCacheFile(true)
-
-}
-
-func (c *fileBackedCache) getCacheFile(unlink bool) (string, error) {
+func (c *fileBackedCache) getCacheFile(name string, unlink bool) (string, error) {
 	u, err := user.Current()
 	if err != nil {
 		return "", err
 	}
-	path := u.HomeDir + string(filepath.Separator) + cacheFileName
+	path := u.HomeDir + string(filepath.Separator) + name
 	_, err = os.Stat(path)
 	if err != nil && !unlink {
 		return "", err
@@ -88,3 +140,71 @@ func (c *fileBackedCache) getCacheFile(unlink bool) (string, error) {
 	}
 	return path, nil
 }
+
+// Resolves ids to labels using the named label cache. The cache is refreshed with fetch
+// when one of ids isn't in it, unless fetching was forbidden before (e.g. the user isn't
+// an administrator), in which case it isn't tried again until the cache is cleared.
+// Ids that weren't found by the last fetch aren't fetched again for labelMissTTL, and
+// ids of 0 (e.g. a VM without a hypervisor) are never fetched.
+// Ids without a label are formatted with fallback.
+func (ctx *cli) cachedLabels(name string, ids []int, fetch func() (map[int]string, error), fallback string) func(int) string {
+	var cached labelCache
+	if ctx.cache != nil {
+		var err error
+		if cached, err = ctx.cache.GetLabels(name); err != nil && err != ErrCacheDoesntExist {
+			log.Warnf("Skipping the %s cache: %s\n", name, err.Error())
+		}
+	}
+	if cached.Labels == nil {
+		cached.Labels = map[int]string{}
+	}
+	if cached.Missing == nil {
+		cached.Missing = map[int]time.Time{}
+	}
+	now := time.Now()
+	missing := false
+	for _, id := range ids {
+		if _, ok := cached.Labels[id]; ok || id == 0 {
+			continue
+		}
+		if at, ok := cached.Missing[id]; ok && now.Sub(at) < labelMissTTL {
+			continue
+		}
+		missing = true
+		break
+	}
+	if missing && !cached.Forbidden {
+		labels, err := fetch()
+		switch {
+		case err == nil:
+			cached.Labels = labels
+			for id, at := range cached.Missing {
+				if _, ok := labels[id]; ok || now.Sub(at) >= labelMissTTL {
+					delete(cached.Missing, id)
+				}
+			}
+			for _, id := range ids {
+				if _, ok := labels[id]; !ok && id != 0 {
+					cached.Missing[id] = now
+				}
+			}
+		case onapp.IsForbidden(err) || onapp.IsUnauthorized(err):
+			cached.Forbidden = true
+		default:
+			log.Warnf("Couldn't look up %s labels: %v\n", name, err)
+		}
+		if err == nil || cached.Forbidden {
+			if ctx.cache != nil {
+				if err := ctx.cache.StoreLabels(name, cached); err != nil {
+					log.Warnf("Unable to save the %s cache: %v\n", name, err)
+				}
+			}
+		}
+	}
+	return func(id int) string {
+		if l, ok := cached.Labels[id]; ok {
+			return l
+		}
+		return fmt.Sprintf(fallback, id)
+	}
+}
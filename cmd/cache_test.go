@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexzorin/onapp"
+)
+
+// A Cache that only lives as long as the test
+type memoryCache struct {
+	vms    onapp.VirtualMachines
+	labels map[string]labelCache
+}
+
+func (c *memoryCache) GetVirtualMachines() (onapp.VirtualMachines, error) {
+	if c.vms == nil {
+		return nil, ErrCacheDoesntExist
+	}
+	return c.vms, nil
+}
+
+func (c *memoryCache) Clear() {
+	*c = memoryCache{}
+}
+
+func (c *memoryCache) Store(vms onapp.VirtualMachines) error {
+	c.vms = vms
+	return nil
+}
+
+func (c *memoryCache) GetLabels(name string) (labelCache, error) {
+	l, ok := c.labels[name]
+	if !ok {
+		return labelCache{}, ErrCacheDoesntExist
+	}
+	return l, nil
+}
+
+func (c *memoryCache) StoreLabels(name string, labels labelCache) error {
+	if c.labels == nil {
+		c.labels = map[string]labelCache{}
+	}
+	c.labels[name] = labels
+	return nil
+}
+
+func TestCachedLabels(t *testing.T) {
+	ctx := &cli{cache: &memoryCache{}}
+	var fetches int
+	fetch := func() (map[int]string, error) {
+		fetches++
+		return map[int]string{1: "hv1", 2: "hv2"}, nil
+	}
+
+	label := ctx.cachedLabels("hv", []int{1, 2}, fetch, "HV-%d")
+	if label(1) != "hv1" || label(3) != "HV-3" {
+		t.Errorf("Unexpected labels: %s, %s", label(1), label(3))
+	}
+	// Everything's cached the second time around
+	ctx.cachedLabels("hv", []int{2}, fetch, "HV-%d")
+	if fetches != 1 {
+		t.Errorf("Expected a single fetch, got %d", fetches)
+	}
+	// An unknown id refreshes the cache
+	ctx.cachedLabels("hv", []int{3}, fetch, "HV-%d")
+	if fetches != 2 {
+		t.Errorf("Expected the unknown id to cause a fetch, got %d fetches", fetches)
+	}
+}
+
+func TestCachedLabelsMissing(t *testing.T) {
+	cache := &memoryCache{}
+	ctx := &cli{cache: cache}
+	var fetches int
+	fetch := func() (map[int]string, error) {
+		fetches++
+		return map[int]string{1: "hv1"}, nil
+	}
+
+	// 5 doesn't exist (e.g. a deleted user) and 0 means there isn't one
+	for i := 0; i < 3; i++ {
+		if l := ctx.cachedLabels("hv", []int{0, 1, 5}, fetch, "HV-%d")(5); l != "HV-5" {
+			t.Errorf("Unexpected label: %s", l)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("A known miss shouldn't be fetched again, got %d fetches", fetches)
+	}
+
+	// Once the miss has expired it's looked up again
+	l := cache.labels["hv"]
+	l.Missing[5] = time.Now().Add(-labelMissTTL - time.Minute)
+	ctx.cachedLabels("hv", []int{5}, fetch, "HV-%d")
+	if fetches != 2 {
+		t.Errorf("Expected an expired miss to be fetched again, got %d fetches", fetches)
+	}
+	if _, ok := cache.labels["hv"].Missing[0]; ok {
+		t.Error("Id 0 shouldn't be recorded as a miss")
+	}
+}
+
+func TestCachedLabelsForbidden(t *testing.T) {
+	ctx := &cli{cache: &memoryCache{}}
+	var fetches int
+	fetch := func() (map[int]string, error) {
+		fetches++
+		return nil, &onapp.APIError{StatusCode: 403}
+	}
+	for i := 0; i < 3; i++ {
+		if l := ctx.cachedLabels("hv", []int{1}, fetch, "HV-%d")(1); l != "HV-1" {
+			t.Errorf("Unexpected label: %s", l)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("A forbidden lookup shouldn't be retried, got %d fetches", fetches)
+	}
+
+	// Other errors are tried again next time
+	ctx = &cli{cache: &memoryCache{}}
+	fetches = 0
+	failing := func() (map[int]string, error) {
+		fetches++
+		return nil, errors.New("connection refused")
+	}
+	ctx.cachedLabels("hv", []int{1}, failing, "HV-%d")
+	ctx.cachedLabels("hv", []int{1}, failing, "HV-%d")
+	if fetches != 2 {
+		t.Errorf("Expected a failed lookup to be retried, got %d fetches", fetches)
+	}
+}
@@ -1,13 +1,18 @@
 package cmd
 
 import (
+	"bufio"
 	"errors"
 	"flag"
 	"fmt"
 	"github.com/alexzorin/onapp"
 	"github.com/alexzorin/onapp/log"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 type cli struct {
@@ -15,6 +20,7 @@ type cli struct {
 	caller    string
 	apiClient *onapp.Client
 	cache     Cache
+	flags     []string
 }
 
 type cmdHandler interface {
@@ -31,10 +37,15 @@ type cmdHandlerSubhandlers interface {
 }
 
 var cmdHandlers = map[string]cmdHandler{
-	"config": configCmd{},
-	"vm":     vmCmd{},
-	"test":   testCmd{},
-	"help":   helpCmd{},
+	"config":    configCmd{},
+	"vm":        vmCmd{},
+	"template":  templateCmd{},
+	"hv":        hvCmd{},
+	"datastore": dataStoreCmd{},
+	"report":    reportCmd{},
+	"tx":        txCmd{},
+	"test":      testCmd{},
+	"help":      helpCmd{},
 }
 
 func (c *cli) parse(args []string) {
@@ -64,7 +75,7 @@ func Start() {
 	if err != nil {
 		log.Errorf(err.Error())
 	}
-	cli := cli{conf, filepath.Base(os.Args[0]), cl, &fileBackedCache{}}
+	cli := cli{conf, filepath.Base(os.Args[0]), cl, &fileBackedCache{}, cleanFlags(os.Args[1:])}
 	cli.parse(cleanArgs(os.Args[1:]))
 }
 
@@ -76,6 +87,15 @@ func (c *cli) subhandle(handler cmdHandlerSubhandlers, args []string) error {
 	return errors.New(fmt.Sprintf("Sub-command %s doesn't exist", args[0]))
 }
 
+// Prints the help text of a nested command (such as `vm ip`) and lists its subcommands
+func printSubhandlers(name string, help string, handler cmdHandlerSubhandlers) {
+	log.Infoln(help)
+	log.Infof("`%s' has a number of sub-commands:\n", name)
+	for k, v := range *handler.Handlers() {
+		log.Infof("  %10s   %s\n", k, v.Description())
+	}
+}
+
 func printUsage() {
 	log.Infoln("Available commands\n")
 	for k, v := range cmdHandlers {
@@ -87,6 +107,83 @@ func printUsage() {
 	log.InfoToggle(false)
 }
 
+// The counterpart to cleanArgs, so that subcommands can look at their own flags
+func cleanFlags(args []string) []string {
+	out := make([]string, 0)
+	for _, v := range args {
+		if len(v) > 0 && v[0] == '-' {
+			out = append(out, strings.TrimLeft(v, "-"))
+		}
+	}
+	return out
+}
+
+// Whether -name or --name was passed
+func (c *cli) hasFlag(name string) bool {
+	for _, f := range c.flags {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// The value of -name=value or --name=value, if passed
+func (c *cli) flagValue(name string) (string, bool) {
+	for _, f := range c.flags {
+		if strings.HasPrefix(f, name+"=") {
+			return f[len(name)+1:], true
+		}
+	}
+	return "", false
+}
+
+// Reports that an action was queued, along with its transaction if the dashboard returned one
+func logQueued(tx onapp.Transaction, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if tx.IsValid() {
+		msg += fmt.Sprintf(": transaction #%d", tx.Id)
+	}
+	log.Successln(msg)
+}
+
+// Asks the user a yes/no question, returning an error unless they answer yes
+func confirm(format string, args ...interface{}) error {
+	log.Warnf(format+" [y/n]: ", args...)
+	reader := bufio.NewReader(os.Stdin)
+	resp, err := reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if len(resp) == 0 || strings.ToLower(resp)[0] != 'y' {
+		return errors.New("User cancelled action")
+	}
+	return nil
+}
+
+// Asks the user to type the label of the VM that's about to be destroyed, or its id
+// if it has no label, returning an error unless it matches exactly
+func confirmVm(vm onapp.VirtualMachine, action string) error {
+	return confirmVmFrom(os.Stdin, vm, action)
+}
+
+func confirmVmFrom(r io.Reader, vm onapp.VirtualMachine, action string) error {
+	field, expected := "label", vm.Label
+	// Otherwise just pressing enter would confirm
+	if strings.TrimSpace(vm.Label) == "" {
+		field, expected = "id", strconv.Itoa(vm.Id)
+	}
+	log.Infof("Type the %s of the virtual machine to confirm: ", field)
+	resp, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if strings.Trim(resp, "\r\n") != expected {
+		return fmt.Errorf("The %s didn't match, not %s", field, action)
+	}
+	return nil
+}
+
 func cleanArgs(args []string) []string {
 	out := make([]string, 0)
 	for _, v := range args {
@@ -96,3 +193,52 @@ func cleanArgs(args []string) []string {
 	}
 	return out
 }
+
+// Prompts for the password given by a bare --password flag, returning "" without one.
+// --password=<password> is refused, since it would show up in the process list and shell history.
+func (c *cli) passwordFlag() (string, error) {
+	if _, ok := c.flagValue("password"); ok {
+		return "", errors.New("Don't put the password on the command line, pass --password on its own to be prompted for it")
+	}
+	if !c.hasFlag("password") {
+		return "", nil
+	}
+	log.Infof("Password: ")
+	// Hide the input where stty is available, it's only cosmetic if not
+	if stty("-echo") == nil {
+		defer func() {
+			stty("echo")
+			log.Infoln()
+		}()
+	}
+	return readLine(os.Stdin)
+}
+
+func stty(arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
+
+// Reads up to the next newline a byte at a time, so that nothing after it is consumed
+// and later prompts reading from the same input still see it
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n > 0 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimRight(string(line), "\r"), nil
+}
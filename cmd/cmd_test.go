@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alexzorin/onapp"
+)
+
+func TestConfirmVm(t *testing.T) {
+	labelled := onapp.VirtualMachine{Id: 12, Label: "web1"}
+	unlabelled := onapp.VirtualMachine{Id: 12}
+	cases := []struct {
+		vm    onapp.VirtualMachine
+		input string
+		ok    bool
+	}{
+		{labelled, "web1\n", true},
+		{labelled, "web1\r\n", true},
+		{labelled, "web\n", false},
+		{labelled, "\n", false},
+		{labelled, "12\n", false},
+		// Pressing enter mustn't confirm destroying a VM without a label
+		{unlabelled, "\n", false},
+		{unlabelled, "", false},
+		{unlabelled, "12\n", true},
+	}
+	for i, c := range cases {
+		err := confirmVmFrom(strings.NewReader(c.input), c.vm, "deleting")
+		if (err == nil) != c.ok {
+			t.Errorf("Case %d: %q for %+v gave %v", i, c.input, c.vm, err)
+		}
+	}
+}
+
+func TestReadLine(t *testing.T) {
+	r := strings.NewReader("s3cret!\r\nweb1\n")
+	if l, err := readLine(r); err != nil || l != "s3cret!" {
+		t.Errorf("Unexpected first line %q: %v", l, err)
+	}
+	// The rest is left for the next prompt
+	if err := confirmVmFrom(r, onapp.VirtualMachine{Id: 12, Label: "web1"}, "rebuilding"); err != nil {
+		t.Error(err)
+	}
+	if l, err := readLine(strings.NewReader("no newline")); err != nil || l != "no newline" {
+		t.Errorf("Unexpected line %q: %v", l, err)
+	}
+}
+
+func TestPasswordFlag(t *testing.T) {
+	ctx := &cli{flags: []string{"password=s3cret!"}}
+	if _, err := ctx.passwordFlag(); err == nil {
+		t.Error("Expected a password on the command line to be refused")
+	}
+	ctx = &cli{}
+	if p, err := ctx.passwordFlag(); err != nil || p != "" {
+		t.Errorf("Expected no password, got %q %v", p, err)
+	}
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/alexzorin/onapp"
+	"github.com/alexzorin/onapp/log"
+)
+
+const (
+	dataStoreCmdDescription     = "Inspect data stores (requires administrative permissions)"
+	dataStoreCmdHelp            = "See subcommands for help on data stores."
+	dataStoreCmdListDescription = "List data stores with their capacity and usage"
+	dataStoreCmdListHelp        = "\nUsage: `onapp datastore list [filter]`\n" +
+		"Optionally filter by field query, e.g onapp datastore list [Label=san DataStoreType=lvm DataStoreGroupID=1]. (case sensitive)"
+	dataStoreCmdDisksDescription = "List the disks backed by a data store"
+	dataStoreCmdDisksHelp        = "Usage: `onapp datastore disks <id|label>`"
+)
+
+// Base command
+
+type dataStoreCmd struct{}
+
+var dataStoreCmdHandlers = map[string]cmdHandler{
+	"list":  dataStoreCmdList{},
+	"disks": dataStoreCmdDisks{},
+}
+
+func (c dataStoreCmd) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		log.Infoln("This command does nothing when invoked on its own.")
+		cmdHandlers["help"].Run([]string{"datastore"}, ctx)
+		return nil
+	} else {
+		return ctx.subhandle(c, args)
+	}
+}
+
+func (c dataStoreCmd) Description() string {
+	return dataStoreCmdDescription
+}
+
+func (c dataStoreCmd) Help(args []string) {
+	log.Infoln(dataStoreCmdHelp)
+}
+
+func (c dataStoreCmd) Handlers() *map[string]cmdHandler {
+	return &dataStoreCmdHandlers
+}
+
+// List command
+type dataStoreCmdList struct{}
+
+func (c dataStoreCmdList) Run(args []string, ctx *cli) error {
+	dss, err := ctx.apiClient.GetDataStores()
+	if err != nil {
+		return err
+	}
+	zones := map[int]string{}
+	if zs, err := ctx.apiClient.GetDataStoreZones(); err == nil {
+		for _, z := range zs {
+			zones[z.ID] = z.Label
+		}
+	}
+	diskCount := map[int]int{}
+	if disks, err := ctx.apiClient.GetDisks(); err == nil {
+		for _, d := range disks {
+			diskCount[d.DataStoreID]++
+		}
+	} else {
+		log.Warnf("Couldn't count disks: %v\n", err)
+	}
+	asList := ctx.Filter(args, "ID", dss.AsList())
+	log.Infof("%25.25s   #%-4s   %-15.15s   %-10s   %-7s   %-7s   %-7s   %-4s   %s\n",
+		"Label", "ID", "Zone", "Type", "Size", "Used", "Free", "Use%", "Disks")
+	for item := asList.Front(); item != nil; item = item.Next() {
+		ds := (item.Value).(onapp.DataStore)
+		log.Infof("%25.25s   #%-4d   %-15.15s   %-10.10s   %6dG   %6dG   %6dG   %3d%%   %d\n",
+			ds.Label, ds.ID, zones[ds.DataStoreGroupID], ds.DataStoreType,
+			ds.DataStoreSize, ds.Usage, ds.Free(), ds.UsagePercent(), diskCount[ds.ID])
+	}
+	return nil
+}
+
+func (c dataStoreCmdList) Description() string {
+	return dataStoreCmdListDescription
+}
+
+func (c dataStoreCmdList) Help(args []string) {
+	log.Infoln(dataStoreCmdListHelp)
+	log.Infoln("\nField names are as follows: ")
+	log.Infof("%+v\n\n", &onapp.DataStore{})
+}
+
+// Disks command
+type dataStoreCmdDisks struct{}
+
+func (c dataStoreCmdDisks) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	ds, err := ctx.findDataStore(args[0])
+	if err != nil {
+		return err
+	}
+	disks, err := ctx.apiClient.GetDisks()
+	if err != nil {
+		return err
+	}
+	vmLabels := map[int]string{}
+	if vms, err := ctx.apiClient.GetVirtualMachines(); err == nil {
+		for _, vm := range vms {
+			vmLabels[vm.Id] = vm.Label
+		}
+	}
+	log.Infof("Disks on %s (#%d):\n", ds.Label, ds.ID)
+	log.Infof("#%-6s   %-25s   %-6s   %-7s   %-8s   %s\n", "ID", "Label", "Size", "Kind", "VM", "VM Label")
+	for _, d := range disks {
+		if d.DataStoreID != ds.ID {
+			continue
+		}
+		log.Infof("#%-6d   %-25.25s   %5dG   %-7s   #%-7d   %s\n",
+			d.ID, d.Label, d.DiskSize, diskKind(d), d.VirtualMachineID, vmLabels[d.VirtualMachineID])
+	}
+	return nil
+}
+
+func (c dataStoreCmdDisks) Description() string {
+	return dataStoreCmdDisksDescription
+}
+
+func (c dataStoreCmdDisks) Help(args []string) {
+	log.Infoln(dataStoreCmdDisksHelp)
+}
+
+// Finds a data store by exact id or label
+func (ctx *cli) findDataStore(query string) (onapp.DataStore, error) {
+	query = strings.Trim(query, " ")
+	if id, err := strconv.Atoi(query); err == nil {
+		return ctx.apiClient.GetDataStore(id)
+	}
+	dss, err := ctx.apiClient.GetDataStores()
+	if err != nil {
+		return onapp.DataStore{}, err
+	}
+	for _, ds := range dss {
+		if strings.ToLower(ds.Label) == strings.ToLower(query) {
+			return ds, nil
+		}
+	}
+	return onapp.DataStore{}, errors.New("Couldn't find a data store matching that")
+}
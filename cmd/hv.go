@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/alexzorin/onapp"
+	"github.com/alexzorin/onapp/log"
+)
+
+const (
+	hvCmdDescription     = "Inspect hypervisors (requires administrative permissions)"
+	hvCmdHelp            = "See subcommands for help on hypervisors."
+	hvCmdListDescription = "List hypervisors with their free resources and VM count"
+	hvCmdListHelp        = "\nUsage: `onapp hv list [filter]`\n" +
+		"Optionally filter by field query, e.g onapp hv list [Label=hv1 Online=true HypervisorGroupID=2]. (case sensitive)"
+)
+
+// Base command
+
+type hvCmd struct{}
+
+var hvCmdHandlers = map[string]cmdHandler{
+	"list": hvCmdList{},
+}
+
+func (c hvCmd) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		log.Infoln("This command does nothing when invoked on its own.")
+		cmdHandlers["help"].Run([]string{"hv"}, ctx)
+		return nil
+	} else {
+		return ctx.subhandle(c, args)
+	}
+}
+
+func (c hvCmd) Description() string {
+	return hvCmdDescription
+}
+
+func (c hvCmd) Help(args []string) {
+	log.Infoln(hvCmdHelp)
+}
+
+func (c hvCmd) Handlers() *map[string]cmdHandler {
+	return &hvCmdHandlers
+}
+
+// List command
+type hvCmdList struct{}
+
+func (c hvCmdList) Run(args []string, ctx *cli) error {
+	hvs, err := ctx.apiClient.GetHypervisors()
+	if err != nil {
+		return err
+	}
+	vms, err := ctx.apiClient.GetVirtualMachines()
+	if err != nil {
+		return err
+	}
+	vmCount := map[int]int{}
+	for _, vm := range vms {
+		vmCount[vm.HV]++
+	}
+	zones := map[int]string{}
+	if zs, err := ctx.apiClient.GetHypervisorZones(); err == nil {
+		for _, z := range zs {
+			zones[z.ID] = z.Label
+		}
+	}
+	asList := ctx.Filter(args, "ID", hvs.AsList())
+	log.Infof("%25.25s   #%-4s   %-15.15s   %-5s   %-8s   %-18s   %-5s   %-6s   %s\n",
+		"Label", "ID", "Zone", "Type", "Status", "Free / Total RAM", "Cores", "Idle", "VMs")
+	for item := asList.Front(); item != nil; item = item.Next() {
+		hv := (item.Value).(onapp.Hypervisor)
+		log.Infof("%25.25s   #%-4d   %-15.15s   %-5.5s   %-17s   %7dM / %6dM   %5d   %5d%%   %d\n",
+			hv.Label, hv.ID, zones[hv.HypervisorGroupID], hv.HypervisorType, hvStatusColored(hv),
+			hv.FreeMemory, hv.TotalMemory, hv.CpuCores, hv.CpuIdle, vmCount[hv.ID])
+	}
+	return nil
+}
+
+func (c hvCmdList) Description() string {
+	return hvCmdListDescription
+}
+
+func (c hvCmdList) Help(args []string) {
+	log.Infoln(hvCmdListHelp)
+	log.Infoln("\nField names are as follows: ")
+	log.Infof("%+v\n\n", &onapp.Hypervisor{})
+}
+
+func hvStatusColored(hv onapp.Hypervisor) string {
+	switch {
+	case !hv.Online:
+		return log.ColorString("Offline ", log.RED)
+	case !hv.Enabled || hv.Locked:
+		return log.ColorString("Disabled", log.YELLOW)
+	}
+	return log.ColorString("Online  ", log.GREEN)
+}
+
+// Labels of the VMs' hypervisors by id, falling back to HV-<id> for any that can't
+// be looked up (e.g. the user isn't an administrator). The labels are cached.
+func (ctx *cli) hypervisorLabels(vms onapp.VirtualMachines) func(int) string {
+	ids := make([]int, len(vms))
+	for i, vm := range vms {
+		ids[i] = vm.HV
+	}
+	return ctx.cachedLabels(hvLabelsCacheName, ids, func() (map[int]string, error) {
+		hvs, err := ctx.apiClient.GetHypervisors()
+		if err != nil {
+			return nil, err
+		}
+		labels := make(map[int]string, len(hvs))
+		for _, hv := range hvs {
+			labels[hv.ID] = hv.Label
+		}
+		return labels, nil
+	}, "HV-%d")
+}
+
+// Finds a hypervisor by exact id or label
+func (ctx *cli) findHypervisor(query string) (onapp.Hypervisor, error) {
+	query = strings.Trim(query, " ")
+	if id, err := strconv.Atoi(query); err == nil {
+		return ctx.apiClient.GetHypervisor(id)
+	}
+	hvs, err := ctx.apiClient.GetHypervisors()
+	if err != nil {
+		return onapp.Hypervisor{}, err
+	}
+	for _, hv := range hvs {
+		if strings.ToLower(hv.Label) == strings.ToLower(query) {
+			return hv, nil
+		}
+	}
+	return onapp.Hypervisor{}, errors.New("Couldn't find a hypervisor matching that")
+}
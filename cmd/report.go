@@ -0,0 +1,267 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alexzorin/onapp"
+	"github.com/alexzorin/onapp/log"
+)
+
+const (
+	reportCmdDescription        = "Reports on the state of the cloud"
+	reportCmdHelp               = "See subcommands for help on reports."
+	reportCmdBackupsDescription = "Reports failing, stale and missing disk autobackups"
+	reportCmdBackupsHelp        = "Usage: `onapp report backups [--json] [--slack=<duration>] [--workers=<n>]`\n" +
+		"Walks the schedules of every VM's disks and reports schedules with failures, schedules that haven't\n" +
+		"succeeded within their interval plus --slack (default 24h, e.g 12h) and disks without autobackups enabled.\n" +
+		"--workers limits how many VMs are inspected at once (default 8), --json prints the report as JSON."
+
+	reportProblemFailures     = "failures"
+	reportProblemStale        = "stale"
+	reportProblemNoAutobackup = "no autobackups"
+)
+
+// Base command
+
+type reportCmd struct{}
+
+var reportCmdHandlers = map[string]cmdHandler{
+	"backups": reportCmdBackups{},
+}
+
+func (c reportCmd) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		log.Infoln("This command does nothing when invoked on its own.")
+		cmdHandlers["help"].Run([]string{"report"}, ctx)
+		return nil
+	} else {
+		return ctx.subhandle(c, args)
+	}
+}
+
+func (c reportCmd) Description() string {
+	return reportCmdDescription
+}
+
+func (c reportCmd) Help(args []string) {
+	log.Infoln(reportCmdHelp)
+}
+
+func (c reportCmd) Handlers() *map[string]cmdHandler {
+	return &reportCmdHandlers
+}
+
+// Backups command
+type reportCmdBackups struct{}
+
+type backupReportEntry struct {
+	VmID         int    `json:"vm_id"`
+	VmLabel      string `json:"vm_label"`
+	DiskID       int    `json:"disk_id"`
+	DiskLabel    string `json:"disk_label"`
+	ScheduleID   int    `json:"schedule_id,omitempty"`
+	Problem      string `json:"problem"`
+	FailureCount int    `json:"failure_count"`
+	LastSuccess  string `json:"last_success,omitempty"`
+	LastError    string `json:"last_error,omitempty"`
+}
+
+type backupReport struct {
+	Entries []backupReportEntry `json:"entries"`
+	// VMs that couldn't be inspected, by id
+	Errors map[int]string `json:"errors,omitempty"`
+}
+
+type backupReportEntries []backupReportEntry
+
+func (e backupReportEntries) Len() int      { return len(e) }
+func (e backupReportEntries) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e backupReportEntries) Less(i, j int) bool {
+	if e[i].VmLabel != e[j].VmLabel {
+		return e[i].VmLabel < e[j].VmLabel
+	}
+	if e[i].DiskID != e[j].DiskID {
+		return e[i].DiskID < e[j].DiskID
+	}
+	return e[i].ScheduleID < e[j].ScheduleID
+}
+
+func (c reportCmdBackups) Run(args []string, ctx *cli) error {
+	slack := 24 * time.Hour
+	if v, ok := ctx.flagValue("slack"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return fmt.Errorf("Invalid --slack value: %s", v)
+		}
+		slack = d
+	}
+	workers := 8
+	if v, ok := ctx.flagValue("workers"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("Invalid --workers value: %s", v)
+		}
+		workers = n
+	}
+
+	vms, err := ctx.apiClient.GetVirtualMachines()
+	if err != nil {
+		return err
+	}
+
+	report := backupReport{Entries: []backupReportEntry{}, Errors: map[int]string{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+	now := time.Now()
+	for _, vm := range vms {
+		wg.Add(1)
+		go func(vm onapp.VirtualMachine) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			entries, err := inspectVmBackups(ctx.apiClient, vm, now, slack)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				report.Errors[vm.Id] = err.Error()
+				return
+			}
+			report.Entries = append(report.Entries, entries...)
+		}(vm)
+	}
+	wg.Wait()
+	sort.Sort(backupReportEntries(report.Entries))
+
+	if ctx.hasFlag("json") {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	var failed []int
+	labels := map[int]string{}
+	for _, vm := range vms {
+		if _, ok := report.Errors[vm.Id]; ok {
+			failed = append(failed, vm.Id)
+			labels[vm.Id] = vm.Label
+		}
+	}
+	sort.Ints(failed)
+	for _, id := range failed {
+		log.Warnf("Couldn't inspect #%d %s: %s\n", id, labels[id], report.Errors[id])
+	}
+	if len(report.Entries) == 0 {
+		log.Successf("No backup problems found across %d VMs\n", len(vms))
+		return nil
+	}
+	log.Infof("%20.20s   %-15.15s   #%-6s   %-14s   %-8s   %-25s   %s\n",
+		"VM", "Disk", "Sched", "Problem", "Failures", "Last Success", "Last Error")
+	for _, e := range report.Entries {
+		sched := "-"
+		if e.ScheduleID != 0 {
+			sched = strconv.Itoa(e.ScheduleID)
+		}
+		lastSuccess := e.LastSuccess
+		if lastSuccess == "" {
+			lastSuccess = "never"
+		}
+		log.Infof("%20.20s   %-15.15s   #%-6s   %-14s   %-8d   %-25s   %s\n",
+			e.VmLabel, fmt.Sprintf("#%d %s", e.DiskID, e.DiskLabel), sched,
+			reportProblemColored(e.Problem), e.FailureCount, lastSuccess, firstLine(e.LastError))
+	}
+	return nil
+}
+
+// Collects the backup problems of one VM's disks as of now
+func inspectVmBackups(c *onapp.Client, vm onapp.VirtualMachine, now time.Time, slack time.Duration) ([]backupReportEntry, error) {
+	disks, err := c.GetVirtualMachineDisks(vm.Id)
+	if err != nil {
+		return nil, err
+	}
+	entries := []backupReportEntry{}
+	for _, d := range disks {
+		if d.IsSwap {
+			continue
+		}
+		base := backupReportEntry{VmID: vm.Id, VmLabel: vm.Label, DiskID: d.ID, DiskLabel: d.Label}
+		if !d.HasAutobackups {
+			e := base
+			e.Problem = reportProblemNoAutobackup
+			entries = append(entries, e)
+			continue
+		}
+		schedules, err := c.GetVirtualMachineDiskSchedules(vm.Id, d.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range schedules {
+			e := base
+			e.ScheduleID = s.ID
+			e.FailureCount = s.FailureCount
+			last, ok := s.LastSuccess()
+			if ok {
+				e.LastSuccess = last.Format(time.RFC3339)
+			}
+			for _, l := range s.LatestLogs(len(s.ScheduleLogs)) {
+				if l.Log.Status == "failed" {
+					e.LastError = l.Log.LogOutput
+					break
+				}
+			}
+			switch {
+			case s.FailureCount > 0:
+				e.Problem = reportProblemFailures
+			case scheduleStale(s, now, slack):
+				e.Problem = reportProblemStale
+			default:
+				continue
+			}
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// Whether the schedule has gone longer than its interval plus slack without succeeding.
+// A schedule that has never succeeded is measured from when it was created, so that a new
+// one isn't stale before it's had a chance to run. Schedules with an unknown period fall back to a day.
+func scheduleStale(s onapp.DiskSchedule, now time.Time, slack time.Duration) bool {
+	interval, ok := s.Interval()
+	if !ok {
+		interval = 24 * time.Hour
+	}
+	last, ok := s.LastSuccess()
+	if !ok {
+		created, err := time.Parse(time.RFC3339, s.CreatedAt)
+		if err != nil {
+			return true
+		}
+		last = created
+	}
+	return last.Before(now.Add(-interval - slack))
+}
+
+func (c reportCmdBackups) Description() string {
+	return reportCmdBackupsDescription
+}
+
+func (c reportCmdBackups) Help(args []string) {
+	log.Infoln(reportCmdBackupsHelp)
+}
+
+func reportProblemColored(problem string) string {
+	color := log.YELLOW
+	if problem == reportProblemFailures {
+		color = log.RED
+	}
+	return log.ColorString(fmt.Sprintf("%-14s", problem), color)
+}
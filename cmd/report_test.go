@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alexzorin/onapp"
+)
+
+func scheduleSucceededAt(period string, duration int, last time.Time) onapp.DiskSchedule {
+	s := onapp.DiskSchedule{Period: period, Duration: duration}
+	var l onapp.DiskScheduleLog
+	l.Log.Status = "complete"
+	l.Log.CreatedAt = last.Format(time.RFC3339)
+	s.ScheduleLogs = []onapp.DiskScheduleLog{l}
+	return s
+}
+
+func TestScheduleStale(t *testing.T) {
+	now := time.Date(2015, 3, 20, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	for _, c := range []struct {
+		schedule onapp.DiskSchedule
+		stale    bool
+	}{
+		{scheduleSucceededAt("days", 1, now.Add(-30*time.Hour)), false},
+		{scheduleSucceededAt("days", 1, now.Add(-3*day)), true},
+		// A weekly schedule is fine after 3 days, a 48h threshold would have flagged it
+		{scheduleSucceededAt("weeks", 1, now.Add(-3*day)), false},
+		{scheduleSucceededAt("weeks", 1, now.Add(-9*day)), true},
+		{scheduleSucceededAt("months", 1, now.Add(-20*day)), false},
+		// Never succeeded, and either too new to have run, too old, or of unknown age
+		{onapp.DiskSchedule{Period: "days", Duration: 1, CreatedAt: now.Add(-time.Minute).Format(time.RFC3339)}, false},
+		{onapp.DiskSchedule{Period: "days", Duration: 1, CreatedAt: now.Add(-3 * day).Format(time.RFC3339)}, true},
+		{onapp.DiskSchedule{Period: "days", Duration: 1}, true},
+	} {
+		if stale := scheduleStale(c.schedule, now, day); stale != c.stale {
+			t.Errorf("%d %s: expected stale %v, got %v", c.schedule.Duration, c.schedule.Period, c.stale, stale)
+		}
+	}
+}
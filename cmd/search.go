@@ -2,8 +2,10 @@ package cmd
 
 import (
 	"container/list"
+	"errors"
 	"github.com/alexzorin/onapp/log"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 )
@@ -13,6 +15,51 @@ type search struct {
 	query string
 }
 
+var searchPattern = regexp.MustCompile("^(\\w+)=(\\w+)$")
+
+// Parses `Field=value` queries from the command line, a bare number
+// is taken as a search on idField.
+func parseSearches(args []string, idField string) []search {
+	var searches []search
+	for _, s := range args {
+		trimmed := strings.Trim(s, " ")
+		matches := searchPattern.FindStringSubmatch(trimmed)
+		if len(matches) != 3 {
+			_, err := strconv.Atoi(trimmed)
+			if err == nil {
+				searches = append(searches, search{idField, trimmed})
+				break
+			} else {
+				log.Warnf("Search query '%s' isn't valid\n", s)
+			}
+		} else {
+			searches = append(searches, search{matches[1], matches[2]})
+		}
+	}
+	return searches
+}
+
+// Parses the queries in args and narrows items down by each in turn
+func (c *cli) Filter(args []string, idField string, items list.List) list.List {
+	for _, s := range parseSearches(args, idField) {
+		items = c.Search(s, items)
+	}
+	return items
+}
+
+// Like Filter, but for commands that act on every match: a query that couldn't be
+// parsed is an error rather than being skipped, as is matching nothing at all.
+func (c *cli) FilterStrict(args []string, idField string, items list.List) (list.List, error) {
+	if len(parseSearches(args, idField)) != len(args) {
+		return list.List{}, errors.New("Refusing to continue as not every search query could be used")
+	}
+	matches := c.Filter(args, idField, items)
+	if matches.Len() == 0 {
+		return list.List{}, errors.New("Nothing matched the search")
+	}
+	return matches, nil
+}
+
 // This searches via reflect
 // It takes q.name, finds the field of that name (case sensitive) and returns any matches on q.value
 // String fields use strings.contains
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/alexzorin/onapp"
+)
+
+func TestFilterStrict(t *testing.T) {
+	vms := onapp.VirtualMachines{
+		{Id: 1, Label: "web1"},
+		{Id: 2, Label: "web2"},
+		{Id: 3, Label: "db1"},
+	}
+	ctx := &cli{}
+
+	matches, err := ctx.FilterStrict([]string{"Label=web"}, "Id", vms.AsList())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matches.Len() != 2 {
+		t.Errorf("Expected 2 matches, got %d", matches.Len())
+	}
+	if matches, err = ctx.FilterStrict([]string{"3"}, "Id", vms.AsList()); err != nil || matches.Len() != 1 {
+		t.Errorf("Expected VM 3 to match: %v", err)
+	}
+
+	// An unparseable query would otherwise be dropped, leaving every VM matched
+	if _, err := ctx.FilterStrict([]string{"Label=web", "web-*"}, "Id", vms.AsList()); err == nil {
+		t.Error("Expected an invalid query to be rejected")
+	}
+	if _, err := ctx.FilterStrict([]string{"Label=mail"}, "Id", vms.AsList()); err == nil {
+		t.Error("Expected no matches to be rejected")
+	}
+}
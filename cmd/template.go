@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/alexzorin/onapp"
+	"github.com/alexzorin/onapp/log"
+)
+
+const (
+	templateCmdDescription     = "Browse the templates that virtual machines are built from"
+	templateCmdHelp            = "See subcommands for help on templates."
+	templateCmdListDescription = "List templates available to your account"
+	templateCmdListHelp        = "\nUsage: `onapp template list [filter]`\n" +
+		"Optionally filter by field query, e.g onapp template list [Label=ubuntu OperatingSystem=linux MinMemorySize=512]. (case sensitive)"
+)
+
+// Base command
+
+type templateCmd struct{}
+
+var templateCmdHandlers = map[string]cmdHandler{
+	"list": templateCmdList{},
+}
+
+func (c templateCmd) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		log.Infoln("This command does nothing when invoked on its own.")
+		cmdHandlers["help"].Run([]string{"template"}, ctx)
+		return nil
+	} else {
+		return ctx.subhandle(c, args)
+	}
+}
+
+func (c templateCmd) Description() string {
+	return templateCmdDescription
+}
+
+func (c templateCmd) Help(args []string) {
+	log.Infoln(templateCmdHelp)
+}
+
+func (c templateCmd) Handlers() *map[string]cmdHandler {
+	return &templateCmdHandlers
+}
+
+// List command
+type templateCmdList struct{}
+
+func (c templateCmdList) Run(args []string, ctx *cli) error {
+	tpls, err := ctx.apiClient.GetTemplates()
+	if err != nil {
+		return err
+	}
+	asList := ctx.Filter(args, "ID", tpls.AsList())
+	log.Infof("%-6s   %40.40s   %-10s   %-12s   %-8s   %-8s   %s\n",
+		"ID", "Label", "OS", "Distro", "Min Disk", "Min RAM", "Virtualization")
+	for item := asList.Front(); item != nil; item = item.Next() {
+		t := (item.Value).(onapp.Template)
+		log.Infof("#%-5d   %40.40s   %-10.10s   %-12.12s   %7dG   %7dM   %s\n",
+			t.ID, t.Label, t.OperatingSystem, t.OperatingSystemDistro, t.MinDiskSize, t.MinMemorySize,
+			strings.Join(t.Virtualization, ","))
+	}
+	return nil
+}
+
+func (c templateCmdList) Description() string {
+	return templateCmdListDescription
+}
+
+func (c templateCmdList) Help(args []string) {
+	log.Infoln(templateCmdListHelp)
+	log.Infoln("\nField names are as follows: ")
+	log.Infof("%+v\n\n", &onapp.Template{})
+}
+
+// Finds a template by id, or by label allowing for inexact matches
+// (with confirmation) in the same way as findVm
+func (ctx *cli) findTemplate(query string) (onapp.Template, error) {
+	query = strings.Trim(strings.ToLower(query), " ")
+	if id, err := strconv.Atoi(query); err == nil {
+		return ctx.apiClient.GetTemplate(id)
+	}
+	tpls, err := ctx.apiClient.GetTemplates()
+	if err != nil {
+		return onapp.Template{}, err
+	}
+	var candidate onapp.Template
+	candidateDist := 1000
+	for _, t := range tpls {
+		if strings.ToLower(t.Label) == query {
+			return t, nil
+		}
+		if dist := compare(strings.ToLower(t.Label), query); dist < candidateDist {
+			candidate = t
+			candidateDist = dist
+		}
+	}
+	if candidate.ID == 0 {
+		return candidate, errors.New("Couldn't find a template matching that")
+	}
+	if err := confirm("Inexact match found for '%s': (#%d, %s) - do you want to continue?", query, candidate.ID, candidate.Label); err != nil {
+		return onapp.Template{}, err
+	}
+	return candidate, nil
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alexzorin/onapp"
+	"github.com/alexzorin/onapp/log"
+)
+
+const (
+	txCmdDescription       = "Inspect and cancel transactions"
+	txCmdHelp              = "See subcommands for help on transactions. To list a VM's transactions use `onapp vm tx <id>`."
+	txCmdShowDescription   = "Shows a transaction along with its log output"
+	txCmdShowHelp          = "Usage: `onapp tx show <transaction id>`"
+	txCmdCancelDescription = "Cancels a pending transaction"
+	txCmdCancelHelp        = "Usage: `onapp tx cancel <transaction id>`, only transactions that haven't started can be cancelled"
+)
+
+// Base command
+
+type txCmd struct{}
+
+var txCmdHandlers = map[string]cmdHandler{
+	"show":   txCmdShow{},
+	"cancel": txCmdCancel{},
+}
+
+func (c txCmd) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		log.Infoln("This command does nothing when invoked on its own.")
+		cmdHandlers["help"].Run([]string{"tx"}, ctx)
+		return nil
+	} else {
+		return ctx.subhandle(c, args)
+	}
+}
+
+func (c txCmd) Description() string {
+	return txCmdDescription
+}
+
+func (c txCmd) Help(args []string) {
+	log.Infoln(txCmdHelp)
+}
+
+func (c txCmd) Handlers() *map[string]cmdHandler {
+	return &txCmdHandlers
+}
+
+// Show command
+type txCmdShow struct{}
+
+func (c txCmdShow) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return err
+	}
+	tx, err := ctx.apiClient.GetTransaction(id)
+	if err != nil {
+		return err
+	}
+	log.Infof("Transaction #%d   %s   %s\n", tx.Id, tx.Action, tx.StatusColored())
+	log.Infof("  On:        %s #%d\n", tx.ParentType, tx.Parent)
+	log.Infof("  User:      %d\n", tx.User)
+	log.Infof("  Created:   %s\n", tx.CreatedAt)
+	log.Infof("  Started:   %s\n", tx.StartedAt)
+	log.Infof("  Updated:   %s\n", tx.UpdatedAt)
+	if tx.Dependent != 0 {
+		log.Infof("  Waits on:  #%d\n", tx.Dependent)
+	}
+	if strings.TrimSpace(tx.LogOutput) == "" {
+		log.Infoln("\nNo log output")
+		return nil
+	}
+	log.Infoln("\nLog output:")
+	log.Infoln(strings.TrimRight(tx.LogOutput, "\n"))
+	return nil
+}
+
+func (c txCmdShow) Description() string {
+	return txCmdShowDescription
+}
+
+func (c txCmdShow) Help(args []string) {
+	log.Infoln(txCmdShowHelp)
+}
+
+// Cancel command
+type txCmdCancel struct{}
+
+func (c txCmdCancel) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return err
+	}
+	err = ctx.apiClient.CancelTransaction(id)
+	if err == onapp.ErrTransactionNotPending {
+		status := "no longer pending"
+		if tx, err := ctx.apiClient.GetTransaction(id); err == nil {
+			status = tx.Status
+		}
+		return fmt.Errorf("Transaction #%d is %s, it can't be cancelled", id, status)
+	}
+	if err != nil {
+		return err
+	}
+	log.Successf("Cancelled transaction #%d\n", id)
+	return nil
+}
+
+func (c txCmdCancel) Description() string {
+	return txCmdCancelDescription
+}
+
+func (c txCmdCancel) Help(args []string) {
+	log.Infoln(txCmdCancelHelp)
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -9,7 +10,6 @@ import (
 	"os"
 	"os/exec"
 	"os/user"
-	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -31,11 +31,11 @@ const (
 	vmCmdStartDescription        = "Boots a virtual machine"
 	vmCmdStartHelp               = "Boots virtual machine by id: `onapp vm start <id>."
 	vmCmdStopDescription         = "Stops a virtual machine"
-	vmCmdStopHelp                = "Stops a virtual machine by id: `onapp vm stop <id>`."
+	vmCmdStopHelp                = "Stops a virtual machine by id: `onapp vm stop <id> [--force]`, --force powers it off without a graceful shutdown."
 	vmCmdRebootDescription       = "Reboots a virtual machine"
 	vmCmdRebootHelp              = "Reboots a virtual machine by id: `onapp vm stop <id>`."
 	vmCmdTransactionsDescription = "Lists recent transactions on a virtual machine"
-	vmCmdTransactionsHelp        = "Usage: `onapp vm transactions <id> [number_to_list]`"
+	vmCmdTransactionsHelp        = "Usage: `onapp vm tx <id> [number_to_list] [--tree]`, --tree groups all of the VM's transactions into dependency chains with their durations (fetching every page)"
 	vmCmdSshDescription          = "Uses SSH and the known root password to login to the machine"
 	vmCmdSshHelp                 = "Usage: `onapp vm ssh <id>`, will connect on <first_ip>:22 as root with the known root password"
 	vmCmdVncDescription          = "Opens vncviewer and provides the password for the virtual machine"
@@ -48,8 +48,13 @@ const (
 	vmCmdClearCacheHelp          = "Usage: `onapp vm clear-cache`"
 	vmCmdPassDescription         = "Copies the VM password to the clipboard (or sends it to stdout)"
 	vmCmdPassHelp                = "Usage: `onapp vm pass <id>`, will copy the password to clipboard"
+	vmCmdDeleteDescription       = "Destroys a virtual machine and its disks"
+	vmCmdDeleteHelp              = "Usage: `onapp vm delete <id> [--destroy-backups]`, you will be asked to re-type the VM's label to confirm"
 )
 
+// How long commands wait for a transaction unless --wait-timeout is given
+const defaultWaitTimeout = time.Hour
+
 // Base command
 
 type vmCmd struct{}
@@ -66,6 +71,17 @@ var vmCmdHandlers = map[string]cmdHandler{
 	"vnc":         vmCmdVnc{},
 	"clear-cache": vmCmdClearCache{},
 	"pass":        vmCmdPass{},
+	"delete":      vmCmdDelete{},
+	"ip":          vmCmdIp{},
+	"firewall":    vmCmdFirewall{},
+	"backup":      vmCmdBackup{},
+	"disk":        vmCmdDisk{},
+	"schedule":    vmCmdSchedule{},
+	"migrate":     vmCmdMigrate{},
+	"rebuild":     vmCmdRebuild{},
+	"rotate-pass": vmCmdRotatePass{},
+	"suspend":     vmCmdSuspend{},
+	"recovery":    vmCmdRecovery{},
 }
 
 func (c vmCmd) Run(args []string, ctx *cli) error {
@@ -99,37 +115,43 @@ func (c vmCmdList) Run(args []string, ctx *cli) error {
 		return err
 	}
 	sort.Sort(list)
-	var searches []search
-	pattern := regexp.MustCompile("^(\\w+)=(\\w+)$")
-	for _, s := range args {
-		trimmed := strings.Trim(s, " ")
-		matches := pattern.FindStringSubmatch(trimmed)
-		if len(matches) != 3 {
-			_, err := strconv.Atoi(trimmed)
-			if err == nil {
-				searches = append(searches, search{"Id", trimmed})
-				break
-			} else {
-				log.Warnf("Search query '%s' isn't valid\n", s)
-			}
-		} else {
-			searches = append(searches, search{matches[1], matches[2]})
-		}
-	}
-	asList := list.AsList()
-	for _, s := range searches {
-		asList = ctx.Search(s, asList)
+	asList := ctx.Filter(args, "Id", list.AsList())
+	// Only the VMs being shown should cause their labels to be looked up
+	var shown onapp.VirtualMachines
+	for item := asList.Front(); item != nil; item = item.Next() {
+		shown = append(shown, (item.Value).(onapp.VirtualMachine))
 	}
-	log.Infof("%35.35s   #%-3s   %-5s   %-9s   %-15s   %-8s   %-11s   %-8s\n",
+	hvLabel := ctx.hypervisorLabels(shown)
+	userLogin := ctx.userLogins(shown)
+	log.Infof("%35.35s   #%-3s   %-12s   %-12s   %-15s   %-8s   %-11s   %-8s\n",
 		"Label", "ID", "HV", "User", "First IP", "Status", "CPUs", "RAM")
-	for item := asList.Front(); item != nil; item = item.Next() {
-		vm := (item.Value).(onapp.VirtualMachine)
-		log.Infof("%35.35s   #%-3d   HV-%-2d   User %-4d   %-15s   %-18s %5d  %10dM\n",
-			vm.Label, vm.Id, vm.HV, vm.User, vm.GetIpAddress().Address, vm.BootedStringColored(), vm.Cpus, vm.Memory)
+	for _, vm := range shown {
+		log.Infof("%35.35s   #%-3d   %-12.12s   %-12.12s   %-15s   %-18s %5d  %10dM\n",
+			vm.Label, vm.Id, hvLabel(vm.HV), userLogin(vm.User), vm.GetIpAddress().Address, vm.BootedStringColored(), vm.Cpus, vm.Memory)
 	}
 	return nil
 }
 
+// Resolves user IDs to logins via the user labels cache, refreshing it from the API
+// when one of the VMs belongs to a user it doesn't know about yet. Only administrators
+// can list users, which leaves everyone else with the IDs.
+func (ctx *cli) userLogins(vms onapp.VirtualMachines) func(int) string {
+	ids := make([]int, len(vms))
+	for i, vm := range vms {
+		ids[i] = vm.User
+	}
+	return ctx.cachedLabels(userLabelsCacheName, ids, func() (map[int]string, error) {
+		pager := ctx.apiClient.ListUsers(onapp.ListOptions{})
+		logins := map[int]string{}
+		for pager.Next() {
+			for _, u := range pager.Page() {
+				logins[u.ID] = u.Login
+			}
+		}
+		return logins, pager.Err()
+	}, "User %d")
+}
+
 func (c vmCmdList) Description() string {
 	return vmCmdListDescription
 }
@@ -157,15 +179,18 @@ func (c vmCmdStart) Run(args []string, ctx *cli) error {
 			return busy
 		}
 		err = ctx.apiClient.VirtualMachineStartup(vm.Id)
+		if onapp.IsUnprocessable(err) {
+			return errors.New("VM can't currently be booted")
+		}
 		if err != nil {
 			return err
 		}
-		log.Successf("Job successfully queued, waiting for boot process to start ... ")
-		tx, err := ctx.awaitVmTransaction(vm.Id, "startup_virtual_machine")
+		log.Successln("Job successfully queued, waiting for the boot to complete ...")
+		tx, err := ctx.waitForVmAction(vm.Id, "startup_virtual_machine")
 		if err != nil {
 			return err
 		}
-		log.Successf("Boot process started: #%d!\n", tx.Id)
+		log.Successf("Boot complete: #%d!\n", tx.Id)
 		return nil
 	}
 }
@@ -194,16 +219,26 @@ func (c vmCmdStop) Run(args []string, ctx *cli) error {
 		if busy != nil {
 			return busy
 		}
-		err = ctx.apiClient.VirtualMachineShutdown(vm.Id)
+		if ctx.hasFlag("force") {
+			if err := confirm("Power off %s without shutting it down?", vm.Label); err != nil {
+				return err
+			}
+			err = ctx.apiClient.VirtualMachineStop(vm.Id)
+		} else {
+			err = ctx.apiClient.VirtualMachineShutdown(vm.Id)
+		}
+		if onapp.IsUnprocessable(err) {
+			return errors.New("VM can't currently be shut down")
+		}
 		if err != nil {
 			return err
 		}
-		log.Successf("Job successfully queued, waiting for shutdown process to start ... ")
-		tx, err := ctx.awaitVmTransaction(vm.Id, "stop_virtual_machine")
+		log.Successln("Job successfully queued, waiting for the shutdown to complete ...")
+		tx, err := ctx.waitForVmAction(vm.Id, "stop_virtual_machine")
 		if err != nil {
 			return err
 		}
-		log.Successf("Shutdown process started: #%d!\n", tx.Id)
+		log.Successf("Shutdown complete: #%d!\n", tx.Id)
 		return nil
 	}
 }
@@ -233,15 +268,18 @@ func (c vmCmdReboot) Run(args []string, ctx *cli) error {
 			return busy
 		}
 		err = ctx.apiClient.VirtualMachineReboot(vm.Id)
+		if onapp.IsUnprocessable(err) {
+			return errors.New("VM can't currently be rebooted")
+		}
 		if err != nil {
 			return err
 		}
-		log.Successf("Job successfully queued, waiting for reboot process to start ... ")
-		tx, err := ctx.awaitVmTransaction(vm.Id, "reboot_virtual_machine")
+		log.Successln("Job successfully queued, waiting for the reboot to complete ...")
+		tx, err := ctx.waitForVmAction(vm.Id, "reboot_virtual_machine")
 		if err != nil {
 			return err
 		}
-		log.Successf("Reboot process started: #%d!\n", tx.Id)
+		log.Successf("Reboot complete: #%d!\n", tx.Id)
 		return nil
 	}
 }
@@ -273,10 +311,23 @@ func (c vmCmdTransactions) Run(args []string, ctx *cli) error {
 			return err
 		}
 	}
-	txns, err := ctx.apiClient.VirtualMachineGetTransactions(vm.Id)
+	var txns onapp.Transactions
+	if ctx.hasFlag("tree") {
+		// Chains can span pages, so every transaction is needed to build them
+		txns, err = ctx.apiClient.VirtualMachineListTransactions(vm.Id, onapp.ListOptions{}).All()
+	} else {
+		txns, err = ctx.apiClient.VirtualMachineGetTransactions(vm.Id)
+	}
 	if err != nil {
 		return err
 	}
+	if ctx.hasFlag("tree") {
+		chains := txns.Chains()
+		for i := 0; i < nList && i < len(chains); i++ {
+			printTransactionTree(chains[i], 0)
+		}
+		return nil
+	}
 	for i := 0; i < nList && i < len(txns); i++ {
 		tx := txns[i]
 		t, err := tx.CreatedAtTime()
@@ -284,11 +335,32 @@ func (c vmCmdTransactions) Run(args []string, ctx *cli) error {
 			log.Errorln(err)
 			continue
 		}
-		log.Infof("%25.25s   #%-6d   %-25.25s   %10s\n", t, tx.Id, tx.Action, tx.StatusColored())
+		log.Infof("%25.25s   #%-6d   %-25.25s   %s\n", t, tx.Id, tx.Action, tx.StatusColoredWidth(10))
 	}
 	return nil
 }
 
+func printTransactionTree(n *onapp.TransactionNode, depth int) {
+	prefix := ""
+	if depth > 0 {
+		prefix = strings.Repeat("   ", depth-1) + "└─ "
+	}
+	took := "-"
+	if d, ok := n.Duration(); ok {
+		// Running transactions are timed to now, which is more precise than is useful
+		took = (d - d%time.Second).String()
+	}
+	// Keep the columns aligned by narrowing the action as the tree deepens
+	width := 30 - 3*depth
+	if width < 10 {
+		width = 10
+	}
+	log.Infof("%s#%-6d   %-*.*s   %s   %s\n", prefix, n.Id, width, width, n.Action, n.StatusColoredWidth(10), took)
+	for _, dep := range n.Dependents {
+		printTransactionTree(dep, depth+1)
+	}
+}
+
 func (c vmCmdTransactions) Description() string {
 	return vmCmdTransactionsDescription
 }
@@ -622,37 +694,68 @@ func (ctx *cli) findVm(query string, useCache bool) (onapp.VirtualMachine, error
 	}
 }
 
-func (ctx *cli) awaitVmTransaction(vmId int, transType string) (onapp.Transaction, error) {
-	var timeout int
-	start := time.Now().Add(-5 * time.Second)
-	for {
-		if timeout > 30 {
-			return onapp.Transaction{}, errors.New("Gave up waiting for transaction")
-		}
-		txns, err := ctx.apiClient.VirtualMachineGetTransactions(vmId)
-		if err != nil {
-			return onapp.Transaction{}, err
-		}
-		for i := 0; i < 5 && i < len(txns); i++ {
-			tx := txns[i]
-			t, err := tx.CreatedAtTime()
-			// Couldn't parse time
-			if err != nil {
-				continue
-			}
-			// Job started before our job
-			if t.Before(start) {
-				break
-			}
-			// Wrong type of job
-			if tx.Action != transType {
-				continue
-			}
-			return tx, nil
+// Waits for the VM's transaction with the given action to complete, reporting each change of status
+func (ctx *cli) waitForVmAction(vmId int, action string) (onapp.Transaction, error) {
+	wctx, cancel, err := ctx.waitContext()
+	if err != nil {
+		return onapp.Transaction{}, err
+	}
+	defer cancel()
+	updates, done := logTransactionUpdates()
+	tx, err := ctx.apiClient.VirtualMachineWaitForAction(wctx, vmId, action, updates)
+	<-done
+	return tx, waitError(err)
+}
+
+// Waits for tx to complete, reporting each change of status. If tx isn't valid
+// because the dashboard hadn't queued it yet, waits for the VM's action instead.
+func (ctx *cli) waitForTransaction(vmId int, tx onapp.Transaction, action string) (onapp.Transaction, error) {
+	if !tx.IsValid() {
+		return ctx.waitForVmAction(vmId, action)
+	}
+	wctx, cancel, err := ctx.waitContext()
+	if err != nil {
+		return onapp.Transaction{}, err
+	}
+	defer cancel()
+	updates, done := logTransactionUpdates()
+	tx, err = ctx.apiClient.WaitForTransactionUpdates(wctx, tx.Id, updates)
+	<-done
+	return tx, waitError(err)
+}
+
+// Bounds how long a transaction is waited for, by --wait-timeout or defaultWaitTimeout
+func (ctx *cli) waitContext() (context.Context, context.CancelFunc, error) {
+	timeout := defaultWaitTimeout
+	if v, ok := ctx.flagValue("wait-timeout"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, nil, fmt.Errorf("Invalid --wait-timeout value: %s", v)
 		}
-		<-time.After(5 * time.Second)
-		timeout += 5
+		timeout = d
 	}
+	wctx, cancel := context.WithTimeout(context.Background(), timeout)
+	return wctx, cancel, nil
+}
+
+func waitError(err error) error {
+	if err == context.DeadlineExceeded {
+		return errors.New("Gave up waiting for the transaction, it may still complete (see --wait-timeout)")
+	}
+	return err
+}
+
+// Logs the transactions sent on the returned channel until it's closed, then closes done
+func logTransactionUpdates() (chan onapp.Transaction, chan struct{}) {
+	updates := make(chan onapp.Transaction)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for tx := range updates {
+			log.Infof("Transaction #%d (%s) is %s\n", tx.Id, tx.Action, tx.StatusColored())
+		}
+	}()
+	return updates, done
 }
 
 func (ctx *cli) checkVmBusy(id int) error {
@@ -700,3 +803,67 @@ func (c vmCmdPass) Description() string {
 func (c vmCmdPass) Help(args []string) {
 	log.Infoln(vmCmdPassHelp)
 }
+
+// delete command
+type vmCmdDelete struct{}
+
+func (c vmCmdDelete) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+
+	vm, err := ctx.findVm(args[0], true)
+	if err != nil {
+		return err
+	}
+	disks, err := ctx.apiClient.GetVirtualMachineDisks(vm.Id)
+	if err != nil {
+		return err
+	}
+	backups, err := ctx.apiClient.GetVirtualMachineBackups(vm.Id)
+	if err != nil {
+		return err
+	}
+	opts := onapp.VirtualMachineDeleteOptions{DestroyAllBackups: ctx.hasFlag("destroy-backups")}
+
+	log.Warnf("This will destroy #%d %s (%s, %s)\n", vm.Id, vm.Label, vm.Hostname, vm.GetIpAddress().Address)
+	for _, d := range disks {
+		log.Infof("  Disk #%-6d   %-25.25s   %5dG   %s\n", d.ID, d.Label, d.DiskSize, diskKind(d))
+	}
+	if opts.DestroyAllBackups {
+		log.Infof("  and all %d of its backups\n", len(backups))
+	} else if len(backups) > 0 {
+		log.Infof("  its %d backups will be kept, pass --destroy-backups to remove them too\n", len(backups))
+	}
+	if err := confirmVm(vm, "deleting"); err != nil {
+		return err
+	}
+
+	if err := ctx.apiClient.DeleteVirtualMachine(vm.Id, opts); err != nil {
+		return err
+	}
+	if ctx.cache != nil {
+		ctx.cache.Clear()
+	}
+	log.Successf("Deletion of #%d %s queued\n", vm.Id, vm.Label)
+	return nil
+}
+
+func (c vmCmdDelete) Description() string {
+	return vmCmdDeleteDescription
+}
+
+func (c vmCmdDelete) Help(args []string) {
+	log.Infoln(vmCmdDeleteHelp)
+}
+
+func diskKind(d onapp.Disk) string {
+	switch {
+	case d.Primary:
+		return "primary"
+	case d.IsSwap:
+		return "swap"
+	}
+	return "data"
+}
@@ -0,0 +1,232 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alexzorin/onapp"
+	"github.com/alexzorin/onapp/log"
+)
+
+const (
+	vmBackupCmdDescription        = "Manage the backups of a virtual machine"
+	vmBackupCmdHelp               = "Usage: `onapp vm backup <list|create|restore|delete|prune> <id> ...`"
+	vmBackupCmdListDescription    = "Lists the backups of a virtual machine"
+	vmBackupCmdListHelp           = "Usage: `onapp vm backup list <id>`"
+	vmBackupCmdCreateDescription  = "Takes a backup of a virtual machine's disk"
+	vmBackupCmdCreateHelp         = "Usage: `onapp vm backup create <id> [note] [--disk=<disk id>]`, backs up the primary disk unless --disk is given"
+	vmBackupCmdRestoreDescription = "Restores a backup over the disk it was taken from"
+	vmBackupCmdRestoreHelp        = "Usage: `onapp vm backup restore <id> <backup id>`, the VM will be shut down during the restore"
+	vmBackupCmdDeleteDescription  = "Deletes a backup"
+	vmBackupCmdDeleteHelp         = "Usage: `onapp vm backup delete <id> <backup id>`"
+)
+
+type vmCmdBackup struct{}
+
+var vmBackupCmdHandlers = map[string]cmdHandler{
+	"list":    vmBackupCmdList{},
+	"create":  vmBackupCmdCreate{},
+	"restore": vmBackupCmdRestore{},
+	"delete":  vmBackupCmdDelete{},
+	"prune":   vmBackupCmdPrune{},
+}
+
+func (c vmCmdBackup) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	return ctx.subhandle(c, args)
+}
+
+func (c vmCmdBackup) Description() string {
+	return vmBackupCmdDescription
+}
+
+func (c vmCmdBackup) Help(args []string) {
+	printSubhandlers("vm backup", vmBackupCmdHelp, c)
+}
+
+func (c vmCmdBackup) Handlers() *map[string]cmdHandler {
+	return &vmBackupCmdHandlers
+}
+
+// backup list command
+type vmBackupCmdList struct{}
+
+func (c vmBackupCmdList) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	vm, err := ctx.findVm(args[0], true)
+	if err != nil {
+		return err
+	}
+	backups, err := ctx.apiClient.GetVirtualMachineBackups(vm.Id)
+	if err != nil {
+		return err
+	}
+	printBackups(backups)
+	return nil
+}
+
+func (c vmBackupCmdList) Description() string {
+	return vmBackupCmdListDescription
+}
+
+func (c vmBackupCmdList) Help(args []string) {
+	log.Infoln(vmBackupCmdListHelp)
+}
+
+// backup create command
+type vmBackupCmdCreate struct{}
+
+func (c vmBackupCmdCreate) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	vm, err := ctx.findVm(args[0], true)
+	if err != nil {
+		return err
+	}
+	var diskId int
+	if v, ok := ctx.flagValue("disk"); ok {
+		if diskId, err = strconv.Atoi(v); err != nil {
+			return err
+		}
+	} else {
+		disks, err := ctx.apiClient.GetVirtualMachineDisks(vm.Id)
+		if err != nil {
+			return err
+		}
+		for _, d := range disks {
+			if d.Primary {
+				diskId = d.ID
+			}
+		}
+		if diskId == 0 {
+			return errors.New("Virtual machine doesn't have a primary disk")
+		}
+	}
+	backup, tx, err := ctx.apiClient.CreateDiskBackup(vm.Id, diskId, strings.Join(args[1:], " "))
+	if err != nil {
+		return err
+	}
+	logQueued(tx, "Backup #%d of disk #%d queued", backup.ID, diskId)
+	return nil
+}
+
+func (c vmBackupCmdCreate) Description() string {
+	return vmBackupCmdCreateDescription
+}
+
+func (c vmBackupCmdCreate) Help(args []string) {
+	log.Infoln(vmBackupCmdCreateHelp)
+}
+
+// backup restore command
+type vmBackupCmdRestore struct{}
+
+func (c vmBackupCmdRestore) Run(args []string, ctx *cli) error {
+	if len(args) < 2 {
+		c.Help(args)
+		return nil
+	}
+	vm, backup, err := ctx.findVmBackup(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	if err := confirm("Restoring backup #%d from %s will overwrite disk #%d of %s, continue?",
+		backup.ID, backup.CreatedAt, backup.DiskID, vm.Label); err != nil {
+		return err
+	}
+	tx, err := ctx.apiClient.RestoreBackup(vm.Id, backup.ID)
+	if err != nil {
+		return err
+	}
+	logQueued(tx, "Restore of backup #%d queued", backup.ID)
+	return nil
+}
+
+func (c vmBackupCmdRestore) Description() string {
+	return vmBackupCmdRestoreDescription
+}
+
+func (c vmBackupCmdRestore) Help(args []string) {
+	log.Infoln(vmBackupCmdRestoreHelp)
+}
+
+// backup delete command
+type vmBackupCmdDelete struct{}
+
+func (c vmBackupCmdDelete) Run(args []string, ctx *cli) error {
+	if len(args) < 2 {
+		c.Help(args)
+		return nil
+	}
+	vm, backup, err := ctx.findVmBackup(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	if err := confirm("Delete backup #%d (%s) of %s?", backup.ID, formatSize(backup.BackupSize), vm.Label); err != nil {
+		return err
+	}
+	if err := ctx.apiClient.DeleteVirtualMachineBackup(backup.ID); err != nil {
+		return err
+	}
+	log.Successf("Deleted backup #%d\n", backup.ID)
+	return nil
+}
+
+func (c vmBackupCmdDelete) Description() string {
+	return vmBackupCmdDeleteDescription
+}
+
+func (c vmBackupCmdDelete) Help(args []string) {
+	log.Infoln(vmBackupCmdDeleteHelp)
+}
+
+// Finds a VM and one of its backups by id
+func (ctx *cli) findVmBackup(vmQuery string, backupId string) (onapp.VirtualMachine, onapp.Backup, error) {
+	id, err := strconv.Atoi(backupId)
+	if err != nil {
+		return onapp.VirtualMachine{}, onapp.Backup{}, err
+	}
+	vm, err := ctx.findVm(vmQuery, true)
+	if err != nil {
+		return onapp.VirtualMachine{}, onapp.Backup{}, err
+	}
+	backups, err := ctx.apiClient.GetVirtualMachineBackups(vm.Id)
+	if err != nil {
+		return onapp.VirtualMachine{}, onapp.Backup{}, err
+	}
+	for _, b := range backups {
+		if b.ID == id {
+			return vm, b, nil
+		}
+	}
+	return vm, onapp.Backup{}, fmt.Errorf("Backup #%d doesn't belong to %s", id, vm.Label)
+}
+
+func printBackups(backups onapp.Backups) {
+	log.Infof("#%-6s   %-25s   %-11s   %-9s   %-7s   %-9s   %s\n", "ID", "Created", "Type", "Initiated", "Disk", "Size", "Note")
+	for _, b := range backups {
+		log.Infof("#%-6d   %-25.25s   %-11.11s   %-9.9s   #%-6d   %9s   %s\n",
+			b.ID, b.CreatedAt, b.BackupType, b.Initiated, b.DiskID, formatSize(b.BackupSize), b.Note)
+	}
+}
+
+// Formats a size in KB, as used for backups, in the largest sensible unit
+func formatSize(kb int) string {
+	switch {
+	case kb >= 1024*1024:
+		return fmt.Sprintf("%.1fG", float64(kb)/(1024*1024))
+	case kb >= 1024:
+		return fmt.Sprintf("%.1fM", float64(kb)/1024)
+	}
+	return fmt.Sprintf("%dK", kb)
+}
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+
+	"github.com/alexzorin/onapp"
+	"github.com/alexzorin/onapp/log"
+)
+
+const (
+	vmBackupCmdPruneDescription = "Deletes manual backups beyond a retention policy"
+	vmBackupCmdPruneHelp        = "Usage: `onapp vm backup prune <query> [--keep-last=N] [--keep-daily=N] [--keep-weekly=N] [--dry-run]`\n" +
+		"For each disk of every VM matching the query (as in `onapp vm list`), keeps the N most recent manual backups, the most recent\n" +
+		"backup of each of the last N days and weeks that have backups, and deletes the rest. Automatic backups are never touched."
+)
+
+// Which backups to keep, any backup matching one of the rules is kept
+type retentionPolicy struct {
+	keepLast   int
+	keepDaily  int
+	keepWeekly int
+}
+
+func (p retentionPolicy) isEmpty() bool {
+	return p.keepLast <= 0 && p.keepDaily <= 0 && p.keepWeekly <= 0
+}
+
+type backupsByNewest onapp.Backups
+
+func (b backupsByNewest) Len() int      { return len(b) }
+func (b backupsByNewest) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b backupsByNewest) Less(i, j int) bool {
+	ti, _ := b[i].CreatedAtTime()
+	tj, _ := b[j].CreatedAtTime()
+	return ti.After(tj)
+}
+
+// Splits the manual backups into those to remove under the policy, which is applied
+// to each disk's backups on their own so that one disk can't use up another's.
+// Backups that aren't manual, aren't built or are locked are never removed.
+func (p retentionPolicy) prune(backups onapp.Backups) onapp.Backups {
+	byDisk := map[int]onapp.Backups{}
+	var disks []int
+	for _, b := range backups {
+		if b.Initiated != "manual" || !b.Built || b.Locked {
+			continue
+		}
+		if _, ok := byDisk[b.DiskID]; !ok {
+			disks = append(disks, b.DiskID)
+		}
+		byDisk[b.DiskID] = append(byDisk[b.DiskID], b)
+	}
+	sort.Ints(disks)
+	var remove onapp.Backups
+	for _, d := range disks {
+		remove = append(remove, p.pruneDisk(byDisk[d])...)
+	}
+	return remove
+}
+
+// Applies the policy to the manual backups of a single disk
+func (p retentionPolicy) pruneDisk(candidates onapp.Backups) onapp.Backups {
+	sort.Sort(backupsByNewest(candidates))
+
+	var remove onapp.Backups
+	days := map[string]bool{}
+	weeks := map[string]bool{}
+	for i, b := range candidates {
+		t, err := b.CreatedAtTime()
+		if err != nil {
+			// Keep anything we can't date
+			continue
+		}
+		keep := i < p.keepLast
+		day := t.Format("2006-01-02")
+		if !days[day] && len(days) < p.keepDaily {
+			days[day] = true
+			keep = true
+		}
+		year, wk := t.ISOWeek()
+		week := strconv.Itoa(year) + "-" + strconv.Itoa(wk)
+		if !weeks[week] && len(weeks) < p.keepWeekly {
+			weeks[week] = true
+			keep = true
+		}
+		if !keep {
+			remove = append(remove, b)
+		}
+	}
+	return remove
+}
+
+// backup prune command
+type vmBackupCmdPrune struct{}
+
+func (c vmBackupCmdPrune) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	var policy retentionPolicy
+	for name, dst := range map[string]*int{
+		"keep-last":   &policy.keepLast,
+		"keep-daily":  &policy.keepDaily,
+		"keep-weekly": &policy.keepWeekly,
+	} {
+		if v, ok := ctx.flagValue(name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return errors.New("--" + name + " needs a number")
+			}
+			*dst = n
+		}
+	}
+	if policy.isEmpty() {
+		return errors.New("Refusing to delete every backup, pass at least one of --keep-last, --keep-daily or --keep-weekly")
+	}
+
+	vms, err := ctx.apiClient.GetVirtualMachines()
+	if err != nil {
+		return err
+	}
+	matches, err := ctx.FilterStrict(args, "Id", vms.AsList())
+	if err != nil {
+		return err
+	}
+
+	type pruned struct {
+		vm     onapp.VirtualMachine
+		backup onapp.Backup
+	}
+	var remove []pruned
+	var total int
+	for item := matches.Front(); item != nil; item = item.Next() {
+		vm := (item.Value).(onapp.VirtualMachine)
+		backups, err := ctx.apiClient.GetVirtualMachineBackups(vm.Id)
+		if err != nil {
+			return err
+		}
+		for _, b := range policy.prune(backups) {
+			remove = append(remove, pruned{vm, b})
+			total += b.BackupSize
+		}
+	}
+	if len(remove) == 0 {
+		log.Successln("Nothing to prune")
+		return nil
+	}
+
+	log.Infof("%35.35s   #%-6s   %-25s   #%-6s   %9s   %s\n", "VM", "Backup", "Created", "Disk", "Size", "Note")
+	for _, p := range remove {
+		log.Infof("%35.35s   #%-6d   %-25.25s   #%-6d   %9s   %s\n",
+			p.vm.Label, p.backup.ID, p.backup.CreatedAt, p.backup.DiskID, formatSize(p.backup.BackupSize), p.backup.Note)
+	}
+	log.Infof("\n%d backups, %s would be reclaimed\n", len(remove), formatSize(total))
+	if ctx.hasFlag("dry-run") {
+		return nil
+	}
+	if err := confirm("Delete these %d backups?", len(remove)); err != nil {
+		return err
+	}
+	for _, p := range remove {
+		if err := ctx.apiClient.DeleteVirtualMachineBackup(p.backup.ID); err != nil {
+			log.Errorf("Couldn't delete backup #%d of %s: %v\n", p.backup.ID, p.vm.Label, err)
+			continue
+		}
+		log.Successf("Deleted backup #%d of %s\n", p.backup.ID, p.vm.Label)
+	}
+	return nil
+}
+
+func (c vmBackupCmdPrune) Description() string {
+	return vmBackupCmdPruneDescription
+}
+
+func (c vmBackupCmdPrune) Help(args []string) {
+	log.Infoln(vmBackupCmdPruneHelp)
+}
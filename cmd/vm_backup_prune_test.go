@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alexzorin/onapp"
+)
+
+func backupAt(id int, created time.Time) onapp.Backup {
+	return onapp.Backup{ID: id, CreatedAt: created.Format(time.RFC3339), Initiated: "manual", Built: true}
+}
+
+func prunedIds(backups onapp.Backups) map[int]bool {
+	out := map[int]bool{}
+	for _, b := range backups {
+		out[b.ID] = true
+	}
+	return out
+}
+
+func TestRetentionPolicy(t *testing.T) {
+	// Monday 2 March 2015, two backups a day for 3 weeks, ids increasing with age
+	now := time.Date(2015, 3, 2, 12, 0, 0, 0, time.UTC)
+	var backups onapp.Backups
+	for i := 0; i < 42; i++ {
+		backups = append(backups, backupAt(i+1, now.Add(-time.Duration(i)*12*time.Hour)))
+	}
+	auto := backupAt(100, now.Add(-30*24*time.Hour))
+	auto.Initiated = "daily"
+	backups = append(backups, auto)
+
+	removed := prunedIds(retentionPolicy{keepLast: 3}.prune(backups))
+	if len(removed) != 39 || removed[1] || removed[3] || !removed[4] || removed[100] {
+		t.Errorf("keep-last 3 removed the wrong backups: %v", removed)
+	}
+
+	// Newest backup of today (1) and yesterday (3)
+	removed = prunedIds(retentionPolicy{keepDaily: 2}.prune(backups))
+	if len(removed) != 40 || removed[1] || removed[3] || !removed[2] {
+		t.Errorf("keep-daily 2 removed the wrong backups: %v", removed)
+	}
+
+	// Newest backup of this week (1, Monday) and of last week (3, Sunday)
+	removed = prunedIds(retentionPolicy{keepWeekly: 2}.prune(backups))
+	if len(removed) != 40 || removed[1] || removed[3] {
+		t.Errorf("keep-weekly 2 removed the wrong backups: %v", removed)
+	}
+
+	if !(retentionPolicy{}).isEmpty() {
+		t.Error("Zero policy should be empty")
+	}
+}
+
+func TestRetentionPolicyPerDisk(t *testing.T) {
+	// Disk 1 is backed up every 6 hours and disk 2 once a day in between,
+	// so disk 1's backups are always the most recent
+	now := time.Date(2015, 3, 2, 12, 0, 0, 0, time.UTC)
+	var backups onapp.Backups
+	for i := 0; i < 12; i++ {
+		b := backupAt(i+1, now.Add(-time.Duration(i)*6*time.Hour))
+		b.DiskID = 1
+		backups = append(backups, b)
+	}
+	for i := 0; i < 3; i++ {
+		b := backupAt(100+i, now.Add(-time.Duration(i)*24*time.Hour-time.Hour))
+		b.DiskID = 2
+		backups = append(backups, b)
+	}
+
+	removed := prunedIds(retentionPolicy{keepLast: 2}.prune(backups))
+	if len(removed) != 11 || removed[1] || removed[2] || removed[100] || removed[101] || !removed[102] {
+		t.Errorf("keep-last 2 removed the wrong backups: %v", removed)
+	}
+
+	// Each disk keeps its own newest backup of each day
+	removed = prunedIds(retentionPolicy{keepDaily: 1}.prune(backups))
+	if removed[1] || removed[100] || !removed[101] || !removed[2] {
+		t.Errorf("keep-daily 1 removed the wrong backups: %v", removed)
+	}
+}
@@ -0,0 +1,294 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/alexzorin/onapp"
+	"github.com/alexzorin/onapp/log"
+)
+
+const (
+	vmDiskCmdDescription     = "Manage the disks of a virtual machine"
+	vmDiskCmdHelp            = "Usage: `onapp vm disk <list|add|resize|delete|migrate> <id> ...`"
+	vmDiskCmdListDescription = "Lists the disks of a virtual machine"
+	vmDiskCmdListHelp        = "Usage: `onapp vm disk list <id>`"
+	vmDiskCmdAddDescription  = "Adds a disk to a virtual machine"
+	vmDiskCmdAddHelp         = "Usage: `onapp vm disk add <id> <size GB> <data store id|label> [--label=<label>] [--swap] [--mount=<path>] [--fstab]`\n" +
+		"--fstab formats the disk and adds it to the VM's fstab at --mount"
+	vmDiskCmdResizeDescription  = "Resizes a disk"
+	vmDiskCmdResizeHelp         = "Usage: `onapp vm disk resize <id> <disk id> <size GB>`"
+	vmDiskCmdDeleteDescription  = "Destroys a disk and its contents"
+	vmDiskCmdDeleteHelp         = "Usage: `onapp vm disk delete <id> <disk id>`"
+	vmDiskCmdMigrateDescription = "Moves a disk to another data store"
+	vmDiskCmdMigrateHelp        = "Usage: `onapp vm disk migrate <id> <disk id> <data store id|label>`"
+)
+
+type vmCmdDisk struct{}
+
+var vmDiskCmdHandlers = map[string]cmdHandler{
+	"list":    vmDiskCmdList{},
+	"add":     vmDiskCmdAdd{},
+	"resize":  vmDiskCmdResize{},
+	"delete":  vmDiskCmdDelete{},
+	"migrate": vmDiskCmdMigrate{},
+}
+
+func (c vmCmdDisk) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	return ctx.subhandle(c, args)
+}
+
+func (c vmCmdDisk) Description() string {
+	return vmDiskCmdDescription
+}
+
+func (c vmCmdDisk) Help(args []string) {
+	printSubhandlers("vm disk", vmDiskCmdHelp, c)
+}
+
+func (c vmCmdDisk) Handlers() *map[string]cmdHandler {
+	return &vmDiskCmdHandlers
+}
+
+// disk list command
+type vmDiskCmdList struct{}
+
+func (c vmDiskCmdList) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	vm, err := ctx.findVm(args[0], true)
+	if err != nil {
+		return err
+	}
+	disks, err := ctx.apiClient.GetVirtualMachineDisks(vm.Id)
+	if err != nil {
+		return err
+	}
+	dsLabels := map[int]string{}
+	if dss, err := ctx.apiClient.GetDataStores(); err == nil {
+		for _, ds := range dss {
+			dsLabels[ds.ID] = ds.Label
+		}
+	}
+	log.Infof("#%-6s   %-20s   %-6s   %-7s   %-20s   %-6s   %-12s   %-8s   %s\n",
+		"ID", "Label", "Size", "Kind", "Data Store", "FS", "Mount", "State", "Autobackups")
+	for _, d := range disks {
+		ds := dsLabels[d.DataStoreID]
+		if ds == "" {
+			ds = fmt.Sprintf("#%d", d.DataStoreID)
+		}
+		log.Infof("#%-6d   %-20.20s   %5dG   %-7s   %-20.20s   %-6.6s   %-12.12s   %-8s   %t\n",
+			d.ID, d.Label, d.DiskSize, diskKind(d), ds, d.FileSystem, mountPoint(d), diskState(d), d.HasAutobackups)
+	}
+	return nil
+}
+
+func (c vmDiskCmdList) Description() string {
+	return vmDiskCmdListDescription
+}
+
+func (c vmDiskCmdList) Help(args []string) {
+	log.Infoln(vmDiskCmdListHelp)
+}
+
+// disk add command
+type vmDiskCmdAdd struct{}
+
+func (c vmDiskCmdAdd) Run(args []string, ctx *cli) error {
+	if len(args) < 3 {
+		c.Help(args)
+		return nil
+	}
+	vm, err := ctx.findVm(args[0], true)
+	if err != nil {
+		return err
+	}
+	size, err := strconv.Atoi(args[1])
+	if err != nil {
+		return err
+	}
+	ds, err := ctx.findDataStore(args[2])
+	if err != nil {
+		return err
+	}
+	d := onapp.DiskCreate{
+		DiskSize:    size,
+		DataStoreID: ds.ID,
+		IsSwap:      ctx.hasFlag("swap"),
+	}
+	d.Label, _ = ctx.flagValue("label")
+	d.MountPoint, _ = ctx.flagValue("mount")
+	if ctx.hasFlag("fstab") {
+		d.AddToLinuxFstab = true
+		d.RequireFormatDisk = true
+	}
+	disk, tx, err := ctx.apiClient.CreateDisk(vm.Id, d)
+	if err != nil {
+		return err
+	}
+	logQueued(tx, "Disk #%d (%dG on %s) queued for %s", disk.ID, size, ds.Label, vm.Label)
+	return nil
+}
+
+func (c vmDiskCmdAdd) Description() string {
+	return vmDiskCmdAddDescription
+}
+
+func (c vmDiskCmdAdd) Help(args []string) {
+	log.Infoln(vmDiskCmdAddHelp)
+}
+
+// disk resize command
+type vmDiskCmdResize struct{}
+
+func (c vmDiskCmdResize) Run(args []string, ctx *cli) error {
+	if len(args) < 3 {
+		c.Help(args)
+		return nil
+	}
+	vm, disk, err := ctx.findVmDisk(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	size, err := strconv.Atoi(args[2])
+	if err != nil {
+		return err
+	}
+	if size < disk.DiskSize {
+		if err := confirm("Shrinking disk #%d from %dG to %dG may destroy data, continue?", disk.ID, disk.DiskSize, size); err != nil {
+			return err
+		}
+	}
+	tx, err := ctx.apiClient.ResizeDisk(vm.Id, disk.ID, size)
+	if err != nil {
+		return err
+	}
+	logQueued(tx, "Resize of disk #%d to %dG queued", disk.ID, size)
+	return nil
+}
+
+func (c vmDiskCmdResize) Description() string {
+	return vmDiskCmdResizeDescription
+}
+
+func (c vmDiskCmdResize) Help(args []string) {
+	log.Infoln(vmDiskCmdResizeHelp)
+}
+
+// disk delete command
+type vmDiskCmdDelete struct{}
+
+func (c vmDiskCmdDelete) Run(args []string, ctx *cli) error {
+	if len(args) < 2 {
+		c.Help(args)
+		return nil
+	}
+	vm, disk, err := ctx.findVmDisk(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	if disk.Primary {
+		return errors.New("Refusing to delete the primary disk, delete the VM instead")
+	}
+	if err := confirm("Destroy disk #%d %s (%dG) of %s and everything on it?", disk.ID, disk.Label, disk.DiskSize, vm.Label); err != nil {
+		return err
+	}
+	tx, err := ctx.apiClient.DeleteDisk(vm.Id, disk.ID)
+	if err != nil {
+		return err
+	}
+	logQueued(tx, "Deletion of disk #%d queued", disk.ID)
+	return nil
+}
+
+func (c vmDiskCmdDelete) Description() string {
+	return vmDiskCmdDeleteDescription
+}
+
+func (c vmDiskCmdDelete) Help(args []string) {
+	log.Infoln(vmDiskCmdDeleteHelp)
+}
+
+// disk migrate command
+type vmDiskCmdMigrate struct{}
+
+func (c vmDiskCmdMigrate) Run(args []string, ctx *cli) error {
+	if len(args) < 3 {
+		c.Help(args)
+		return nil
+	}
+	vm, disk, err := ctx.findVmDisk(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	ds, err := ctx.findDataStore(args[2])
+	if err != nil {
+		return err
+	}
+	if ds.ID == disk.DataStoreID {
+		return errors.New("Disk is already on that data store")
+	}
+	if ds.Free() < disk.DiskSize {
+		return fmt.Errorf("%s only has %dG free, disk #%d needs %dG", ds.Label, ds.Free(), disk.ID, disk.DiskSize)
+	}
+	tx, err := ctx.apiClient.MigrateDisk(vm.Id, disk.ID, ds.ID)
+	if err != nil {
+		return err
+	}
+	logQueued(tx, "Migration of disk #%d to %s queued", disk.ID, ds.Label)
+	return nil
+}
+
+func (c vmDiskCmdMigrate) Description() string {
+	return vmDiskCmdMigrateDescription
+}
+
+func (c vmDiskCmdMigrate) Help(args []string) {
+	log.Infoln(vmDiskCmdMigrateHelp)
+}
+
+// Finds a VM and one of its disks by id
+func (ctx *cli) findVmDisk(vmQuery string, diskId string) (onapp.VirtualMachine, onapp.Disk, error) {
+	id, err := strconv.Atoi(diskId)
+	if err != nil {
+		return onapp.VirtualMachine{}, onapp.Disk{}, err
+	}
+	vm, err := ctx.findVm(vmQuery, true)
+	if err != nil {
+		return onapp.VirtualMachine{}, onapp.Disk{}, err
+	}
+	disks, err := ctx.apiClient.GetVirtualMachineDisks(vm.Id)
+	if err != nil {
+		return onapp.VirtualMachine{}, onapp.Disk{}, err
+	}
+	for _, d := range disks {
+		if d.ID == id {
+			return vm, d, nil
+		}
+	}
+	return vm, onapp.Disk{}, fmt.Errorf("Disk #%d doesn't belong to %s", id, vm.Label)
+}
+
+func mountPoint(d onapp.Disk) string {
+	if d.MountPoint == nil {
+		return ""
+	}
+	return fmt.Sprint(d.MountPoint)
+}
+
+func diskState(d onapp.Disk) string {
+	switch {
+	case d.Locked:
+		return "Locked"
+	case !d.Built:
+		return "Building"
+	}
+	return "Built"
+}
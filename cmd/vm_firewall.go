@@ -0,0 +1,281 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/alexzorin/onapp"
+	"github.com/alexzorin/onapp/log"
+)
+
+const (
+	vmFirewallCmdDescription       = "Manage the firewall rules of a virtual machine"
+	vmFirewallCmdHelp              = "Usage: `onapp vm firewall <list|export|import|apply|delete> ...`"
+	vmFirewallCmdListDescription   = "Lists the firewall rules of a virtual machine"
+	vmFirewallCmdListHelp          = "Usage: `onapp vm firewall list <id>`"
+	vmFirewallCmdExportDescription = "Prints the firewall rules of a virtual machine as JSON"
+	vmFirewallCmdExportHelp        = "Usage: `onapp vm firewall export <id> > rules.json`"
+	vmFirewallCmdImportDescription = "Adds the rules from a JSON file to one or more virtual machines and applies them"
+	vmFirewallCmdImportHelp        = "Usage: `onapp vm firewall import <file> <id> [id ...] [--replace] [--interface=<id>]`\n" +
+		"Rules are added to each VM's primary interface, --interface=<id> picks another interface and only works with a single VM.\n" +
+		"--replace removes the existing rules first, once every rule in the file has been validated."
+	vmFirewallCmdApplyDescription  = "Applies the firewall rules of a virtual machine"
+	vmFirewallCmdApplyHelp         = "Usage: `onapp vm firewall apply <id>`"
+	vmFirewallCmdDeleteDescription = "Deletes a firewall rule from a virtual machine"
+	vmFirewallCmdDeleteHelp        = "Usage: `onapp vm firewall delete <id> <rule id>`, apply the rules afterwards for it to take effect"
+)
+
+type vmCmdFirewall struct{}
+
+var vmFirewallCmdHandlers = map[string]cmdHandler{
+	"list":   vmFirewallCmdList{},
+	"export": vmFirewallCmdExport{},
+	"import": vmFirewallCmdImport{},
+	"apply":  vmFirewallCmdApply{},
+	"delete": vmFirewallCmdDelete{},
+}
+
+func (c vmCmdFirewall) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	return ctx.subhandle(c, args)
+}
+
+func (c vmCmdFirewall) Description() string {
+	return vmFirewallCmdDescription
+}
+
+func (c vmCmdFirewall) Help(args []string) {
+	printSubhandlers("vm firewall", vmFirewallCmdHelp, c)
+}
+
+func (c vmCmdFirewall) Handlers() *map[string]cmdHandler {
+	return &vmFirewallCmdHandlers
+}
+
+// firewall list command
+type vmFirewallCmdList struct{}
+
+func (c vmFirewallCmdList) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	vm, err := ctx.findVm(args[0], true)
+	if err != nil {
+		return err
+	}
+	rules, err := ctx.apiClient.GetVirtualMachineFirewallRules(vm.Id)
+	if err != nil {
+		return err
+	}
+	log.Infof("#%-6s   %-8s   %-8s   %-18s   %-11s   %s\n", "ID", "Command", "Protocol", "Address", "Port", "Interface")
+	for _, r := range rules {
+		log.Infof("#%-6d   %-8s   %-8s   %-18s   %-11s   #%d\n",
+			r.ID, r.Command, r.Protocol, r.Address, r.Port, r.NetworkInterfaceID)
+	}
+	return nil
+}
+
+func (c vmFirewallCmdList) Description() string {
+	return vmFirewallCmdListDescription
+}
+
+func (c vmFirewallCmdList) Help(args []string) {
+	log.Infoln(vmFirewallCmdListHelp)
+}
+
+// firewall export command
+type vmFirewallCmdExport struct{}
+
+func (c vmFirewallCmdExport) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	vm, err := ctx.findVm(args[0], true)
+	if err != nil {
+		return err
+	}
+	rules, err := ctx.apiClient.GetVirtualMachineFirewallRules(vm.Id)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(rules.Portable(), "", "  ")
+	if err != nil {
+		return err
+	}
+	// Plain stdout so that the output can be redirected to a file
+	fmt.Println(string(data))
+	return nil
+}
+
+func (c vmFirewallCmdExport) Description() string {
+	return vmFirewallCmdExportDescription
+}
+
+func (c vmFirewallCmdExport) Help(args []string) {
+	log.Infoln(vmFirewallCmdExportHelp)
+}
+
+// firewall import command
+type vmFirewallCmdImport struct{}
+
+func (c vmFirewallCmdImport) Run(args []string, ctx *cli) error {
+	if len(args) < 2 {
+		c.Help(args)
+		return nil
+	}
+	data, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	var rules onapp.FirewallRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return errors.New("Couldn't parse the rules file - " + err.Error())
+	}
+	// Catch a bad rules file before any VM is changed
+	if _, err := firewallRulesFor(rules, 1); err != nil {
+		return err
+	}
+	var nicId int
+	if v, ok := ctx.flagValue("interface"); ok {
+		// Interface ids belong to a single VM
+		if len(args[1:]) > 1 {
+			return errors.New("--interface can only be used when importing to a single virtual machine")
+		}
+		if nicId, err = strconv.Atoi(v); err != nil {
+			return err
+		}
+	}
+	for _, q := range args[1:] {
+		vm, err := ctx.findVm(q, true)
+		if err != nil {
+			return err
+		}
+		if err := ctx.importFirewallRules(vm, rules.Portable(), nicId); err != nil {
+			return fmt.Errorf("#%d %s: %v", vm.Id, vm.Label, err)
+		}
+		log.Successf("Applied %d rules to #%d %s\n", len(rules), vm.Id, vm.Label)
+	}
+	return nil
+}
+
+func (ctx *cli) importFirewallRules(vm onapp.VirtualMachine, rules onapp.FirewallRules, nicId int) error {
+	if nicId == 0 {
+		nics, err := ctx.apiClient.GetVirtualMachineNetworkInterfaces(vm.Id)
+		if err != nil {
+			return err
+		}
+		nic, ok := nics.Primary()
+		if !ok {
+			return errors.New("Virtual machine doesn't have any network interfaces")
+		}
+		nicId = nic.ID
+	}
+	create, err := firewallRulesFor(rules, nicId)
+	if err != nil {
+		return err
+	}
+	if ctx.hasFlag("replace") {
+		existing, err := ctx.apiClient.GetVirtualMachineFirewallRules(vm.Id)
+		if err != nil {
+			return err
+		}
+		for _, r := range existing {
+			if err := ctx.apiClient.DeleteFirewallRule(vm.Id, r.ID); err != nil {
+				return err
+			}
+		}
+	}
+	for _, r := range create {
+		if _, err := ctx.apiClient.CreateFirewallRule(vm.Id, r); err != nil {
+			return err
+		}
+	}
+	return ctx.apiClient.ApplyFirewallRules(vm.Id)
+}
+
+// Copies the rules onto the network interface and validates every one of them
+func firewallRulesFor(rules onapp.FirewallRules, nicId int) (onapp.FirewallRules, error) {
+	out := make(onapp.FirewallRules, len(rules))
+	for i, r := range rules {
+		r.NetworkInterfaceID = nicId
+		if err := r.Validate(); err != nil {
+			return nil, fmt.Errorf("Rule %d: %v", i+1, err)
+		}
+		out[i] = r
+	}
+	return out, nil
+}
+
+func (c vmFirewallCmdImport) Description() string {
+	return vmFirewallCmdImportDescription
+}
+
+func (c vmFirewallCmdImport) Help(args []string) {
+	log.Infoln(vmFirewallCmdImportHelp)
+}
+
+// firewall apply command
+type vmFirewallCmdApply struct{}
+
+func (c vmFirewallCmdApply) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	vm, err := ctx.findVm(args[0], true)
+	if err != nil {
+		return err
+	}
+	if err := ctx.apiClient.ApplyFirewallRules(vm.Id); err != nil {
+		return err
+	}
+	log.Successf("Firewall rules applied to #%d %s\n", vm.Id, vm.Label)
+	return nil
+}
+
+func (c vmFirewallCmdApply) Description() string {
+	return vmFirewallCmdApplyDescription
+}
+
+func (c vmFirewallCmdApply) Help(args []string) {
+	log.Infoln(vmFirewallCmdApplyHelp)
+}
+
+// firewall delete command
+type vmFirewallCmdDelete struct{}
+
+func (c vmFirewallCmdDelete) Run(args []string, ctx *cli) error {
+	if len(args) < 2 {
+		c.Help(args)
+		return nil
+	}
+	vm, err := ctx.findVm(args[0], true)
+	if err != nil {
+		return err
+	}
+	ruleId, err := strconv.Atoi(args[1])
+	if err != nil {
+		return err
+	}
+	if err := ctx.apiClient.DeleteFirewallRule(vm.Id, ruleId); err != nil {
+		return err
+	}
+	log.Successf("Deleted rule #%d from #%d %s\n", ruleId, vm.Id, vm.Label)
+	return nil
+}
+
+func (c vmFirewallCmdDelete) Description() string {
+	return vmFirewallCmdDeleteDescription
+}
+
+func (c vmFirewallCmdDelete) Help(args []string) {
+	log.Infoln(vmFirewallCmdDeleteHelp)
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/alexzorin/onapp"
+)
+
+func TestFirewallRulesFor(t *testing.T) {
+	rules := onapp.FirewallRules{
+		{Command: "ACCEPT", Protocol: "TCP", Port: "22"},
+		{Command: "DROP", Protocol: "ICMP"},
+	}
+	out, err := firewallRulesFor(rules, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range out {
+		if r.NetworkInterfaceID != 4 {
+			t.Errorf("Rule wasn't moved to the interface: %+v", r)
+		}
+	}
+	if rules[0].NetworkInterfaceID != 0 {
+		t.Error("The original rules were modified")
+	}
+
+	rules = append(rules, onapp.FirewallRule{Command: "ALLOW", Protocol: "TCP"})
+	if _, err := firewallRulesFor(rules, 4); err == nil {
+		t.Error("Expected an invalid rule to be rejected")
+	}
+}
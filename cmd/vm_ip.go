@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/alexzorin/onapp"
+	"github.com/alexzorin/onapp/log"
+)
+
+const (
+	vmIpCmdDescription     = "Manage the IP addresses of a virtual machine"
+	vmIpCmdHelp            = "Usage: `onapp vm ip <list|add|remove> <id> ...`"
+	vmIpCmdListDescription = "Lists the IP addresses and network interfaces of a virtual machine"
+	vmIpCmdListHelp        = "Usage: `onapp vm ip list <id>`"
+	vmIpCmdAddDescription  = "Assigns a free IP address from a network"
+	vmIpCmdAddHelp         = "Usage: `onapp vm ip add <id> <network id|label> [--interface=<id>] [--rebuild]`\n" +
+		"Uses the primary interface unless --interface is given, --rebuild reconfigures (and reboots) the VM afterwards.\n" +
+		"With no network, lists the available networks."
+	vmIpCmdRemoveDescription = "Removes an IP address from a virtual machine"
+	vmIpCmdRemoveHelp        = "Usage: `onapp vm ip remove <id> <address> [--rebuild]`"
+)
+
+type vmCmdIp struct{}
+
+var vmIpCmdHandlers = map[string]cmdHandler{
+	"list":   vmIpCmdList{},
+	"add":    vmIpCmdAdd{},
+	"remove": vmIpCmdRemove{},
+}
+
+func (c vmCmdIp) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	return ctx.subhandle(c, args)
+}
+
+func (c vmCmdIp) Description() string {
+	return vmIpCmdDescription
+}
+
+func (c vmCmdIp) Help(args []string) {
+	printSubhandlers("vm ip", vmIpCmdHelp, c)
+}
+
+func (c vmCmdIp) Handlers() *map[string]cmdHandler {
+	return &vmIpCmdHandlers
+}
+
+// ip list command
+type vmIpCmdList struct{}
+
+func (c vmIpCmdList) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	vm, err := ctx.findVm(args[0], true)
+	if err != nil {
+		return err
+	}
+	nics, err := ctx.apiClient.GetVirtualMachineNetworkInterfaces(vm.Id)
+	if err != nil {
+		return err
+	}
+	joins, err := ctx.apiClient.GetVirtualMachineIpAddressJoins(vm.Id)
+	if err != nil {
+		return err
+	}
+	nicLabels := map[int]string{}
+	for _, n := range nics {
+		nicLabels[n.ID] = n.Label
+	}
+	log.Infof("%-15s   %-15s   %-15s   %-20s   %s\n", "Address", "Netmask", "Gateway", "Interface", "Network")
+	for _, j := range joins {
+		ip := j.IpAddress
+		log.Infof("%-15s   %-15s   %-15s   %-20.20s   #%d\n",
+			ip.Address, ip.Netmask, ip.Gateway, nicLabels[j.NetworkInterfaceID], ip.NetworkId)
+	}
+	log.Infoln()
+	for _, n := range nics {
+		primary := ""
+		if n.Primary {
+			primary = " (primary)"
+		}
+		log.Infof("Interface #%d %s, MAC %s%s\n", n.ID, n.Label, n.MacAddress, primary)
+	}
+	return nil
+}
+
+func (c vmIpCmdList) Description() string {
+	return vmIpCmdListDescription
+}
+
+func (c vmIpCmdList) Help(args []string) {
+	log.Infoln(vmIpCmdListHelp)
+}
+
+// ip add command
+type vmIpCmdAdd struct{}
+
+func (c vmIpCmdAdd) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	vm, err := ctx.findVm(args[0], true)
+	if err != nil {
+		return err
+	}
+	nets, err := ctx.apiClient.GetNetworks()
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		log.Infoln("Choose one of these networks:")
+		for _, n := range nets {
+			log.Infof("  #%-4d   %s\n", n.ID, n.Label)
+		}
+		return nil
+	}
+	net, err := findNetwork(nets, args[1])
+	if err != nil {
+		return err
+	}
+
+	var nicId int
+	if v, ok := ctx.flagValue("interface"); ok {
+		if nicId, err = strconv.Atoi(v); err != nil {
+			return err
+		}
+	} else {
+		nics, err := ctx.apiClient.GetVirtualMachineNetworkInterfaces(vm.Id)
+		if err != nil {
+			return err
+		}
+		nic, ok := nics.Primary()
+		if !ok {
+			return errors.New("Virtual machine doesn't have any network interfaces")
+		}
+		nicId = nic.ID
+	}
+
+	join, err := ctx.apiClient.AssignFreeIpAddress(vm.Id, nicId, net.ID)
+	if err != nil {
+		return err
+	}
+	log.Successf("Assigned %s to #%d %s\n", join.IpAddress.Address, vm.Id, vm.Label)
+	return ctx.maybeRebuildNetwork(vm)
+}
+
+func (c vmIpCmdAdd) Description() string {
+	return vmIpCmdAddDescription
+}
+
+func (c vmIpCmdAdd) Help(args []string) {
+	log.Infoln(vmIpCmdAddHelp)
+}
+
+// ip remove command
+type vmIpCmdRemove struct{}
+
+func (c vmIpCmdRemove) Run(args []string, ctx *cli) error {
+	if len(args) < 2 {
+		c.Help(args)
+		return nil
+	}
+	vm, err := ctx.findVm(args[0], true)
+	if err != nil {
+		return err
+	}
+	joins, err := ctx.apiClient.GetVirtualMachineIpAddressJoins(vm.Id)
+	if err != nil {
+		return err
+	}
+	for _, j := range joins {
+		if j.IpAddress.Address != args[1] {
+			continue
+		}
+		if err := ctx.apiClient.RemoveIpAddress(vm.Id, j.ID); err != nil {
+			return err
+		}
+		log.Successf("Removed %s from #%d %s\n", args[1], vm.Id, vm.Label)
+		return ctx.maybeRebuildNetwork(vm)
+	}
+	return errors.New("That address isn't assigned to the virtual machine")
+}
+
+func (c vmIpCmdRemove) Description() string {
+	return vmIpCmdRemoveDescription
+}
+
+func (c vmIpCmdRemove) Help(args []string) {
+	log.Infoln(vmIpCmdRemoveHelp)
+}
+
+func (ctx *cli) maybeRebuildNetwork(vm onapp.VirtualMachine) error {
+	if !ctx.hasFlag("rebuild") {
+		log.Infoln("Pass --rebuild to reconfigure the network inside the VM, or use the dashboard later")
+		return nil
+	}
+	if err := ctx.apiClient.RebuildNetwork(vm.Id); err != nil {
+		return err
+	}
+	log.Successln("Network rebuild queued")
+	return nil
+}
+
+// Finds a network by exact id or label
+func findNetwork(nets onapp.Networks, query string) (onapp.Network, error) {
+	query = strings.Trim(query, " ")
+	id, _ := strconv.Atoi(query)
+	for _, n := range nets {
+		if n.ID == id || strings.ToLower(n.Label) == strings.ToLower(query) {
+			return n, nil
+		}
+	}
+	return onapp.Network{}, errors.New("Couldn't find a network matching that")
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/alexzorin/onapp"
+	"github.com/alexzorin/onapp/log"
+)
+
+const (
+	vmCmdMigrateDescription = "Moves a VM to another hypervisor"
+	vmCmdMigrateHelp        = "Usage: `onapp vm migrate <id> <hv id|label> [--cold]`\n" +
+		"Booted VMs are hot migrated unless --cold is passed, in which case the VM must be shut down first.\n" +
+		"The destination must be online and have enough free memory for the VM."
+)
+
+type vmCmdMigrate struct{}
+
+func (c vmCmdMigrate) Run(args []string, ctx *cli) error {
+	if len(args) < 2 {
+		c.Help(args)
+		return nil
+	}
+	vm, err := ctx.findVm(args[0], true)
+	if err != nil {
+		return err
+	}
+	hv, err := ctx.findHypervisor(args[1])
+	if err != nil {
+		return err
+	}
+	if err := checkMigrationTarget(vm, hv); err != nil {
+		return err
+	}
+	hot := vm.Booted && !ctx.hasFlag("cold")
+	kind := "Cold"
+	if hot {
+		kind = "Hot"
+	}
+	if err := ctx.checkVmBusy(vm.Id); err != nil {
+		return err
+	}
+	if err := confirm("%s migrate %s to %s?", kind, vm.Label, hv.Label); err != nil {
+		return err
+	}
+	tx, err := ctx.apiClient.MigrateVirtualMachine(vm.Id, hv.ID, hot)
+	if err != nil {
+		return err
+	}
+	log.Infoln("Migration queued, waiting for it to complete ...")
+	if _, err := ctx.waitForTransaction(vm.Id, tx, onapp.MigrationAction(hot)); err != nil {
+		return err
+	}
+	log.Successf("%s is now on %s\n", vm.Label, hv.Label)
+	return nil
+}
+
+// Checks that the hypervisor can take the VM
+func checkMigrationTarget(vm onapp.VirtualMachine, hv onapp.Hypervisor) error {
+	if hv.ID == vm.HV {
+		return errors.New("The VM is already on that hypervisor")
+	}
+	if !hv.Online || !hv.Enabled || hv.Locked {
+		return fmt.Errorf("%s isn't accepting virtual machines (online: %v, enabled: %v, locked: %v)",
+			hv.Label, hv.Online, hv.Enabled, hv.Locked)
+	}
+	if hv.FreeMemory < vm.Memory {
+		return fmt.Errorf("%s only has %dM of memory free, the VM needs %dM", hv.Label, hv.FreeMemory, vm.Memory)
+	}
+	return nil
+}
+
+func (c vmCmdMigrate) Description() string {
+	return vmCmdMigrateDescription
+}
+
+func (c vmCmdMigrate) Help(args []string) {
+	log.Infoln(vmCmdMigrateHelp)
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/alexzorin/onapp"
+	"github.com/alexzorin/onapp/log"
+)
+
+const (
+	vmCmdSuspendDescription  = "Suspends a virtual machine so that it can't be booted"
+	vmCmdSuspendHelp         = "Usage: `onapp vm suspend <id> [--undo]`, --undo unsuspends the VM again"
+	vmCmdRecoveryDescription = "Boots or reboots a virtual machine into recovery mode"
+	vmCmdRecoveryHelp        = "Usage: `onapp vm recovery <id>`, the VM is booted from the recovery image with its disks attached.\n" +
+		"Log in as root with the VM's root password (see `onapp vm pass`)."
+)
+
+// Suspend command
+type vmCmdSuspend struct{}
+
+func (c vmCmdSuspend) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	vm, err := ctx.findVm(args[0], true)
+	if err != nil {
+		return err
+	}
+	if ctx.hasFlag("undo") {
+		if !vm.Suspended {
+			return errors.New("VM isn't suspended")
+		}
+		if err := ctx.apiClient.VirtualMachineUnsuspend(vm.Id); err != nil {
+			return err
+		}
+		log.Successf("%s is no longer suspended\n", vm.Label)
+		return nil
+	}
+	if vm.Suspended {
+		return errors.New("VM is already suspended")
+	}
+	if err := ctx.checkVmBusy(vm.Id); err != nil {
+		return err
+	}
+	if err := confirm("Suspend %s? It will be stopped if booted", vm.Label); err != nil {
+		return err
+	}
+	err = ctx.apiClient.VirtualMachineSuspend(vm.Id)
+	if onapp.IsUnprocessable(err) {
+		return errors.New("VM can't currently be suspended")
+	}
+	if err != nil {
+		return err
+	}
+	log.Successf("%s is suspended, use `onapp vm suspend %d --undo` to reverse\n", vm.Label, vm.Id)
+	return nil
+}
+
+func (c vmCmdSuspend) Description() string {
+	return vmCmdSuspendDescription
+}
+
+func (c vmCmdSuspend) Help(args []string) {
+	log.Infoln(vmCmdSuspendHelp)
+}
+
+// Recovery command
+type vmCmdRecovery struct{}
+
+func (c vmCmdRecovery) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	vm, err := ctx.findVm(args[0], true)
+	if err != nil {
+		return err
+	}
+	if vm.Suspended {
+		return errors.New("VM is suspended, unsuspend it first")
+	}
+	if err := ctx.checkVmBusy(vm.Id); err != nil {
+		return err
+	}
+	action := "startup_virtual_machine"
+	if vm.Booted {
+		action = "reboot_virtual_machine"
+		err = ctx.apiClient.VirtualMachineRebootRecovery(vm.Id)
+	} else {
+		err = ctx.apiClient.VirtualMachineStartupRecovery(vm.Id)
+	}
+	if onapp.IsUnprocessable(err) {
+		return errors.New("VM can't currently be booted into recovery")
+	}
+	if err != nil {
+		return err
+	}
+	log.Successln("Job successfully queued, waiting for the recovery boot to complete ...")
+	tx, err := ctx.waitForVmAction(vm.Id, action)
+	if err != nil {
+		return err
+	}
+	log.Successf("Recovery boot complete: #%d!\n", tx.Id)
+	return nil
+}
+
+func (c vmCmdRecovery) Description() string {
+	return vmCmdRecoveryDescription
+}
+
+func (c vmCmdRecovery) Help(args []string) {
+	log.Infoln(vmCmdRecoveryHelp)
+}
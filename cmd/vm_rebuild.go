@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"github.com/alexzorin/onapp"
+	"github.com/alexzorin/onapp/log"
+)
+
+const (
+	vmCmdRebuildDescription = "Reinstalls a VM from a template, destroying its primary disk"
+	vmCmdRebuildHelp        = "Usage: `onapp vm rebuild <id> [template id|label] [--password] [--no-start]`\n" +
+		"Reinstalls the VM's current template unless another is given. The template label may be inexact.\n" +
+		"The VM is booted once rebuilt unless --no-start is passed. --password prompts for a new root password."
+)
+
+type vmCmdRebuild struct{}
+
+func (c vmCmdRebuild) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	vm, err := ctx.findVm(args[0], true)
+	if err != nil {
+		return err
+	}
+	var tpl onapp.Template
+	if len(args) > 1 {
+		tpl, err = ctx.findTemplate(args[1])
+	} else {
+		tpl, err = ctx.apiClient.GetTemplate(vm.TemplateId)
+	}
+	if err != nil {
+		return err
+	}
+	r := onapp.VirtualMachineRebuild{TemplateId: tpl.ID, RequiredStartup: !ctx.hasFlag("no-start")}
+	if r.RootPassword, err = ctx.passwordFlag(); err != nil {
+		return err
+	}
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	if err := ctx.checkVmBusy(vm.Id); err != nil {
+		return err
+	}
+
+	log.Warnf("This will reinstall #%d %s (%s) from %s, destroying everything on its primary disk\n",
+		vm.Id, vm.Label, vm.Hostname, tpl.Label)
+	if err := confirmVm(vm, "rebuilding"); err != nil {
+		return err
+	}
+	tx, err := ctx.apiClient.RebuildVirtualMachine(vm.Id, r)
+	if err != nil {
+		return err
+	}
+	logQueued(tx, "Rebuild of %s from %s queued", vm.Label, tpl.Label)
+	return nil
+}
+
+func (c vmCmdRebuild) Description() string {
+	return vmCmdRebuildDescription
+}
+
+func (c vmCmdRebuild) Help(args []string) {
+	log.Infoln(vmCmdRebuildHelp)
+}
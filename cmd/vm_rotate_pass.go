@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/alexzorin/onapp"
+	"github.com/alexzorin/onapp/log"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	vmCmdRotatePassDescription = "Resets the root password of one or more VMs and checks the new one works"
+	vmCmdRotatePassHelp        = "Usage: `onapp vm rotate-pass <query> [--password] [--insecure-host-key]`\n" +
+		"Matches VMs as `onapp vm list` does, e.g `onapp vm rotate-pass Label=test`. Each VM gets a random password\n" +
+		"unless --password is given, which prompts for one. Once the reset is complete the new password is tried over SSH.\n" +
+		"The VMs' host keys are checked against ~/.ssh/known_hosts, --insecure-host-key skips the check."
+
+	// How long to keep trying SSH after the reset, as the VM may be rebooting
+	rotatePassSshAttempts = 12
+	rotatePassSshInterval = 10 * time.Second
+)
+
+type vmCmdRotatePass struct{}
+
+func (c vmCmdRotatePass) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	password, err := ctx.passwordFlag()
+	if err != nil {
+		return err
+	}
+	hostKey, err := ctx.sshHostKeyCallback()
+	if err != nil {
+		return err
+	}
+	vms, err := ctx.apiClient.GetVirtualMachines()
+	if err != nil {
+		return err
+	}
+	matches, err := ctx.FilterStrict(args, "Id", vms.AsList())
+	if err != nil {
+		return err
+	}
+	for item := matches.Front(); item != nil; item = item.Next() {
+		vm := (item.Value).(onapp.VirtualMachine)
+		log.Infof("  #%-6d   %s\n", vm.Id, vm.Label)
+	}
+	if err := confirm("Reset the root password of these %d VMs?", matches.Len()); err != nil {
+		return err
+	}
+
+	var failed int
+	for item := matches.Front(); item != nil; item = item.Next() {
+		vm := (item.Value).(onapp.VirtualMachine)
+		if err := ctx.rotateRootPassword(vm, password, hostKey); err != nil {
+			log.Errorf("%s: %v\n", vm.Label, err)
+			failed++
+			continue
+		}
+		log.Successf("%s: root password reset and verified over SSH\n", vm.Label)
+	}
+	if failed > 0 {
+		return fmt.Errorf("Failed to rotate the password of %d VMs", failed)
+	}
+	return nil
+}
+
+// Resets a VM's root password, waits for the reset to complete and tries the new password over SSH
+func (ctx *cli) rotateRootPassword(vm onapp.VirtualMachine, password string, hostKey ssh.HostKeyCallback) error {
+	// Dialling a blank address would log in to this machine instead
+	if vm.GetIpAddress().Address == "" {
+		return errors.New("The VM has no IP address to verify the new password on, not resetting it")
+	}
+	tx, err := ctx.apiClient.ResetRootPassword(vm.Id, password)
+	if err != nil {
+		return err
+	}
+	if _, err := ctx.waitForTransaction(vm.Id, tx, "reset_root_password"); err != nil {
+		return err
+	}
+	vm, err = ctx.apiClient.GetVirtualMachine(vm.Id)
+	if err != nil {
+		return err
+	}
+	if password != "" && vm.RootPassword != password {
+		return errors.New("The dashboard didn't record the new password")
+	}
+	if !vm.Booted {
+		log.Warnf("%s isn't booted, the new password can't be verified over SSH\n", vm.Label)
+		return nil
+	}
+	addr := vm.GetIpAddress().Address
+	if addr == "" {
+		return errors.New("The VM no longer has an IP address, the new password can't be verified over SSH")
+	}
+	return verifyRootPassword(net.JoinHostPort(addr, "22"), vm.RootPassword, hostKey)
+}
+
+// Checks host keys against ~/.ssh/known_hosts, or not at all with --insecure-host-key
+func (ctx *cli) sshHostKeyCallback() (ssh.HostKeyCallback, error) {
+	if ctx.hasFlag("insecure-host-key") {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	u, err := user.Current()
+	if err != nil {
+		return nil, err
+	}
+	hostKey, err := knownhosts.New(filepath.Join(u.HomeDir, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, errors.New("Couldn't load known_hosts (pass --insecure-host-key to skip checking host keys) - " + err.Error())
+	}
+	return hostKey, nil
+}
+
+// Logs in to addr as root with the password, retrying while the VM may still be rebooting.
+// A host key that doesn't check out won't change by retrying, so that fails straight away.
+func verifyRootPassword(addr, password string, hostKey ssh.HostKeyCallback) error {
+	var keyErr error
+	config := &ssh.ClientConfig{
+		User: "root",
+		Auth: []ssh.AuthMethod{
+			ssh.Password(password),
+		},
+		HostKeyCallback: func(host string, remote net.Addr, key ssh.PublicKey) error {
+			keyErr = hostKey(host, remote, key)
+			return keyErr
+		},
+	}
+	var err error
+	for attempt := 1; attempt <= rotatePassSshAttempts; attempt++ {
+		var client *ssh.Client
+		if client, err = ssh.Dial("tcp", addr, config); err == nil {
+			client.Close()
+			return nil
+		}
+		if keyErr != nil {
+			return errors.New("Couldn't verify the host key of " + addr + " (see --insecure-host-key) - " + keyErr.Error())
+		}
+		if attempt < rotatePassSshAttempts {
+			<-time.After(rotatePassSshInterval)
+		}
+	}
+	return errors.New("Couldn't log in with the new password - " + err.Error())
+}
+
+func (c vmCmdRotatePass) Description() string {
+	return vmCmdRotatePassDescription
+}
+
+func (c vmCmdRotatePass) Help(args []string) {
+	log.Infoln(vmCmdRotatePassHelp)
+}
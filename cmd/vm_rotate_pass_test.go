@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alexzorin/onapp"
+	"golang.org/x/crypto/ssh"
+)
+
+// Accepts SSH connections with the root password until closed, counting the connections
+func sshServer(t *testing.T, password string) (net.Listener, *int32) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == "root" && string(pass) == password {
+				return nil, nil
+			}
+			return nil, errors.New("wrong password")
+		},
+	}
+	config.AddHostKey(signer)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conns int32
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&conns, 1)
+			go func() {
+				defer c.Close()
+				if _, chans, reqs, err := ssh.NewServerConn(c, config); err == nil {
+					go ssh.DiscardRequests(reqs)
+					for ch := range chans {
+						ch.Reject(ssh.Prohibited, "no sessions")
+					}
+				}
+			}()
+		}
+	}()
+	return l, &conns
+}
+
+func TestVerifyRootPassword(t *testing.T) {
+	l, _ := sshServer(t, "s3cret!")
+	defer l.Close()
+	if err := verifyRootPassword(l.Addr().String(), "s3cret!", ssh.InsecureIgnoreHostKey()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVerifyRootPasswordHostKeyMismatch(t *testing.T) {
+	l, conns := sshServer(t, "s3cret!")
+	defer l.Close()
+	reject := func(string, net.Addr, ssh.PublicKey) error {
+		return errors.New("key mismatch")
+	}
+	if err := verifyRootPassword(l.Addr().String(), "s3cret!", reject); err == nil {
+		t.Fatal("Expected the host key to be rejected")
+	}
+	if n := atomic.LoadInt32(conns); n != 1 {
+		t.Errorf("A rejected host key shouldn't be retried, got %d connections", n)
+	}
+}
+
+func TestSshHostKeyCallbackInsecure(t *testing.T) {
+	ctx := &cli{flags: []string{"insecure-host-key"}}
+	if cb, err := ctx.sshHostKeyCallback(); err != nil || cb == nil {
+		t.Errorf("Expected a callback, got %v", err)
+	}
+}
+
+func TestRotateRootPasswordWithoutAddress(t *testing.T) {
+	// No API client, as nothing should be reset
+	ctx := &cli{}
+	vm := onapp.VirtualMachine{Id: 7, Label: "web1", Booted: true}
+	if err := ctx.rotateRootPassword(vm, "", ssh.InsecureIgnoreHostKey()); err == nil {
+		t.Error("Expected a VM without an IP address to be refused")
+	}
+}
@@ -0,0 +1,328 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/alexzorin/onapp"
+	"github.com/alexzorin/onapp/log"
+)
+
+const (
+	vmScheduleCmdDescription     = "Manage the autobackup schedules of a virtual machine's disks"
+	vmScheduleCmdHelp            = "Usage: `onapp vm schedule <list|add|delete|export|apply> ...`"
+	vmScheduleCmdListDescription = "Lists the schedules of each disk with their recent runs"
+	vmScheduleCmdListHelp        = "Usage: `onapp vm schedule list <id>`"
+	vmScheduleCmdAddDescription  = "Adds an autobackup schedule to a disk"
+	vmScheduleCmdAddHelp         = "Usage: `onapp vm schedule add <id> <disk id> <duration> <days|weeks|months|years> <rotation> [--start=<time>]`\n" +
+		"e.g `onapp vm schedule add web1 12 1 days 7` backs up disk #12 every day and keeps 7 backups"
+	vmScheduleCmdDeleteDescription = "Deletes a schedule"
+	vmScheduleCmdDeleteHelp        = "Usage: `onapp vm schedule delete <schedule id>`"
+	vmScheduleCmdExportDescription = "Prints the schedules of a disk as JSON"
+	vmScheduleCmdExportHelp        = "Usage: `onapp vm schedule export <id> [--disk=<disk id>] > schedules.json`, uses the primary disk unless --disk is given"
+	vmScheduleCmdApplyDescription  = "Adds the schedules from a JSON file to every disk of the matching VMs"
+	vmScheduleCmdApplyHelp         = "Usage: `onapp vm schedule apply <file> <query> [--replace] [--primary-only]`\n" +
+		"Applies to every non-swap disk (or only primary disks) of the VMs matching the query (as in `onapp vm list`),\n" +
+		"--replace deletes the disks' existing schedules first. The matching VMs are listed for confirmation before anything is changed."
+)
+
+type vmCmdSchedule struct{}
+
+var vmScheduleCmdHandlers = map[string]cmdHandler{
+	"list":   vmScheduleCmdList{},
+	"add":    vmScheduleCmdAdd{},
+	"delete": vmScheduleCmdDelete{},
+	"export": vmScheduleCmdExport{},
+	"apply":  vmScheduleCmdApply{},
+}
+
+func (c vmCmdSchedule) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	return ctx.subhandle(c, args)
+}
+
+func (c vmCmdSchedule) Description() string {
+	return vmScheduleCmdDescription
+}
+
+func (c vmCmdSchedule) Help(args []string) {
+	printSubhandlers("vm schedule", vmScheduleCmdHelp, c)
+}
+
+func (c vmCmdSchedule) Handlers() *map[string]cmdHandler {
+	return &vmScheduleCmdHandlers
+}
+
+// schedule list command
+type vmScheduleCmdList struct{}
+
+func (c vmScheduleCmdList) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	vm, err := ctx.findVm(args[0], true)
+	if err != nil {
+		return err
+	}
+	disks, err := ctx.apiClient.GetVirtualMachineDisks(vm.Id)
+	if err != nil {
+		return err
+	}
+	for _, d := range disks {
+		schedules, err := ctx.apiClient.GetVirtualMachineDiskSchedules(vm.Id, d.ID)
+		if err != nil {
+			return err
+		}
+		log.Infof("Disk #%d %s (%s, %dG): %d schedules\n", d.ID, d.Label, diskKind(d), d.DiskSize, len(schedules))
+		for _, s := range schedules {
+			log.Infof("  #%-6d   every %d %-6s   keep %-3d   %-8s   %d failures   starts %s\n",
+				s.ID, s.Duration, s.Period, s.RotationPeriod, s.Status, s.FailureCount, s.StartAt)
+			for _, l := range s.LatestLogs(3) {
+				log.Infof("      %-25.25s   %s   %s\n", l.Log.CreatedAt, scheduleLogStatusColored(l.Log.Status, 8),
+					firstLine(l.Log.LogOutput))
+			}
+		}
+	}
+	return nil
+}
+
+func (c vmScheduleCmdList) Description() string {
+	return vmScheduleCmdListDescription
+}
+
+func (c vmScheduleCmdList) Help(args []string) {
+	log.Infoln(vmScheduleCmdListHelp)
+}
+
+// schedule add command
+type vmScheduleCmdAdd struct{}
+
+func (c vmScheduleCmdAdd) Run(args []string, ctx *cli) error {
+	if len(args) < 5 {
+		c.Help(args)
+		return nil
+	}
+	vm, disk, err := ctx.findVmDisk(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	p := onapp.DiskScheduleParams{Action: "autobackup", Period: args[3]}
+	if p.Duration, err = strconv.Atoi(args[2]); err != nil {
+		return err
+	}
+	if p.RotationPeriod, err = strconv.Atoi(args[4]); err != nil {
+		return err
+	}
+	p.StartAt, _ = ctx.flagValue("start")
+	s, err := ctx.apiClient.CreateDiskSchedule(vm.Id, disk.ID, p)
+	if err != nil {
+		return err
+	}
+	log.Successf("Added schedule #%d to disk #%d of %s\n", s.ID, disk.ID, vm.Label)
+	return nil
+}
+
+func (c vmScheduleCmdAdd) Description() string {
+	return vmScheduleCmdAddDescription
+}
+
+func (c vmScheduleCmdAdd) Help(args []string) {
+	log.Infoln(vmScheduleCmdAddHelp)
+}
+
+// schedule delete command
+type vmScheduleCmdDelete struct{}
+
+func (c vmScheduleCmdDelete) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return err
+	}
+	if err := ctx.apiClient.DeleteDiskSchedule(id); err != nil {
+		return err
+	}
+	log.Successf("Deleted schedule #%d\n", id)
+	return nil
+}
+
+func (c vmScheduleCmdDelete) Description() string {
+	return vmScheduleCmdDeleteDescription
+}
+
+func (c vmScheduleCmdDelete) Help(args []string) {
+	log.Infoln(vmScheduleCmdDeleteHelp)
+}
+
+// schedule export command
+type vmScheduleCmdExport struct{}
+
+func (c vmScheduleCmdExport) Run(args []string, ctx *cli) error {
+	if len(args) == 0 {
+		c.Help(args)
+		return nil
+	}
+	vm, err := ctx.findVm(args[0], true)
+	if err != nil {
+		return err
+	}
+	diskId, _ := ctx.flagValue("disk")
+	if diskId == "" {
+		disks, err := ctx.apiClient.GetVirtualMachineDisks(vm.Id)
+		if err != nil {
+			return err
+		}
+		for _, d := range disks {
+			if d.Primary {
+				diskId = strconv.Itoa(d.ID)
+			}
+		}
+	}
+	_, disk, err := ctx.findVmDisk(strconv.Itoa(vm.Id), diskId)
+	if err != nil {
+		return err
+	}
+	schedules, err := ctx.apiClient.GetVirtualMachineDiskSchedules(vm.Id, disk.ID)
+	if err != nil {
+		return err
+	}
+	params := make([]onapp.DiskScheduleParams, len(schedules))
+	for i, s := range schedules {
+		params[i] = s.ScheduleParams()
+	}
+	data, err := json.MarshalIndent(params, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Plain stdout so that the output can be redirected to a file
+	fmt.Println(string(data))
+	return nil
+}
+
+func (c vmScheduleCmdExport) Description() string {
+	return vmScheduleCmdExportDescription
+}
+
+func (c vmScheduleCmdExport) Help(args []string) {
+	log.Infoln(vmScheduleCmdExportHelp)
+}
+
+// schedule apply command
+type vmScheduleCmdApply struct{}
+
+func (c vmScheduleCmdApply) Run(args []string, ctx *cli) error {
+	if len(args) < 2 {
+		c.Help(args)
+		return nil
+	}
+	data, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	var params []onapp.DiskScheduleParams
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errors.New("Couldn't parse the schedules file - " + err.Error())
+	}
+	for _, p := range params {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+
+	vms, err := ctx.apiClient.GetVirtualMachines()
+	if err != nil {
+		return err
+	}
+	matches, err := ctx.FilterStrict(args[1:], "Id", vms.AsList())
+	if err != nil {
+		return err
+	}
+	for item := matches.Front(); item != nil; item = item.Next() {
+		vm := (item.Value).(onapp.VirtualMachine)
+		log.Infof("#%-6d   %s\n", vm.Id, vm.Label)
+	}
+	action := "Add"
+	if ctx.hasFlag("replace") {
+		action = "Replace the existing schedules with"
+	}
+	if err := confirm("%s %d schedules on the disks of these %d VMs?", action, len(params), matches.Len()); err != nil {
+		return err
+	}
+	for item := matches.Front(); item != nil; item = item.Next() {
+		vm := (item.Value).(onapp.VirtualMachine)
+		disks, err := ctx.apiClient.GetVirtualMachineDisks(vm.Id)
+		if err != nil {
+			return err
+		}
+		for _, d := range disks {
+			if d.IsSwap || (ctx.hasFlag("primary-only") && !d.Primary) {
+				continue
+			}
+			if err := ctx.applyDiskSchedules(vm, d, params); err != nil {
+				log.Errorf("Disk #%d of %s: %v\n", d.ID, vm.Label, err)
+				continue
+			}
+			log.Successf("Applied %d schedules to disk #%d of %s\n", len(params), d.ID, vm.Label)
+		}
+	}
+	return nil
+}
+
+func (ctx *cli) applyDiskSchedules(vm onapp.VirtualMachine, d onapp.Disk, params []onapp.DiskScheduleParams) error {
+	if ctx.hasFlag("replace") {
+		existing, err := ctx.apiClient.GetVirtualMachineDiskSchedules(vm.Id, d.ID)
+		if err != nil {
+			return err
+		}
+		for _, s := range existing {
+			if err := ctx.apiClient.DeleteDiskSchedule(s.ID); err != nil {
+				return err
+			}
+		}
+	}
+	for _, p := range params {
+		if _, err := ctx.apiClient.CreateDiskSchedule(vm.Id, d.ID, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c vmScheduleCmdApply) Description() string {
+	return vmScheduleCmdApplyDescription
+}
+
+func (c vmScheduleCmdApply) Help(args []string) {
+	log.Infoln(vmScheduleCmdApplyHelp)
+}
+
+// The status left-aligned to width, then coloured so that the colour codes don't count towards the width
+func scheduleLogStatusColored(status string, width int) string {
+	color := log.YELLOW
+	switch status {
+	case "complete":
+		color = log.GREEN
+	case "failed":
+		color = log.RED
+	}
+	return log.ColorString(fmt.Sprintf("%-*s", width, status), color)
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,13 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScheduleLogStatusColored(t *testing.T) {
+	padded := scheduleLogStatusColored("failed", 8)
+	if !strings.Contains(padded, "failed  ") || strings.Contains(padded, "failed   ") {
+		t.Errorf("Expected the status to be padded to 8 before colouring: %q", padded)
+	}
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alexzorin/onapp"
+)
+
+func TestUserLogins(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/users.json" {
+			t.Errorf("Unexpected request for %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.URL.Query().Get("page") {
+		case "1":
+			fmt.Fprint(w, `[{"user":{"id":1,"login":"admin"}}]`)
+		case "2":
+			fmt.Fprint(w, `[{"user":{"id":2,"login":"alice"}}]`)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	}))
+	defer ts.Close()
+	c, _ := onapp.NewClient(ts.URL, "user@example.org", "1234", onapp.WithRetryPolicy(onapp.RetryPolicy{}))
+	ctx := &cli{apiClient: c, cache: &memoryCache{}}
+	vms := onapp.VirtualMachines{{Id: 7, User: 2}, {Id: 8, User: 3}}
+
+	login := ctx.userLogins(vms)
+	if login(2) != "alice" || login(3) != "User 3" {
+		t.Errorf("Unexpected logins: %s, %s", login(2), login(3))
+	}
+	// Both pages and the empty one that ends the list
+	if requests != 3 {
+		t.Errorf("Expected 3 requests, got %d", requests)
+	}
+
+	requests = 0
+	if login := ctx.userLogins(vms[:1]); login(2) != "alice" {
+		t.Errorf("Unexpected cached login: %s", login(2))
+	}
+	if requests != 0 {
+		t.Errorf("Known users shouldn't be fetched again, got %d requests", requests)
+	}
+}
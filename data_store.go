@@ -0,0 +1,120 @@
+package onapp
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"strconv"
+)
+
+type DataStores []DataStore
+
+// A data store as according to /settings/data_stores.json. Sizes are in GB.
+type DataStore struct {
+	CreatedAt         string `json:"created_at"`
+	DataStoreGroupID  int    `json:"data_store_group_id"`
+	DataStoreSize     int    `json:"data_store_size"`
+	DataStoreType     string `json:"data_store_type"`
+	Enabled           bool   `json:"enabled"`
+	ID                int    `json:"id"`
+	Identifier        string `json:"identifier"`
+	IP                string `json:"ip"`
+	Label             string `json:"label"`
+	LocalHypervisorID int    `json:"local_hypervisor_id"`
+	UpdatedAt         string `json:"updated_at"`
+	Usage             int    `json:"usage"`
+}
+
+type DataStoreZones []DataStoreZone
+
+// A group of data stores as according to /settings/data_store_zones.json
+type DataStoreZone struct {
+	CreatedAt       string `json:"created_at"`
+	ID              int    `json:"id"`
+	Label           string `json:"label"`
+	LocationGroupID int    `json:"location_group_id"`
+	UpdatedAt       string `json:"updated_at"`
+}
+
+// Fetches the data stores from the dashboard server (requires administrative permissions)
+func (c *Client) GetDataStores() (DataStores, error) {
+	return c.GetDataStoresContext(context.Background())
+}
+
+func (c *Client) GetDataStoresContext(ctx context.Context) (DataStores, error) {
+	data, err, _ := c.getReq(ctx, "settings/data_stores.json")
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]DataStore
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return nil, err
+	}
+	dss := make([]DataStore, len(out))
+	for i := range dss {
+		dss[i] = out[i]["data_store"]
+	}
+	return dss, nil
+}
+
+// Fetches an individual data store
+func (c *Client) GetDataStore(id int) (DataStore, error) {
+	return c.GetDataStoreContext(context.Background(), id)
+}
+
+func (c *Client) GetDataStoreContext(ctx context.Context, id int) (DataStore, error) {
+	data, err, _ := c.getReq(ctx, "settings/data_stores/", strconv.Itoa(id), ".json")
+	if err != nil {
+		return DataStore{}, err
+	}
+	var out map[string]DataStore
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return DataStore{}, err
+	}
+	return out["data_store"], nil
+}
+
+// Fetches the data store zones (OnApp's data store groups)
+func (c *Client) GetDataStoreZones() (DataStoreZones, error) {
+	return c.GetDataStoreZonesContext(context.Background())
+}
+
+func (c *Client) GetDataStoreZonesContext(ctx context.Context) (DataStoreZones, error) {
+	data, err, _ := c.getReq(ctx, "settings/data_store_zones.json")
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]DataStoreZone
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return nil, err
+	}
+	zones := make([]DataStoreZone, len(out))
+	for i := range zones {
+		zones[i] = out[i]["data_store_group"]
+	}
+	return zones, nil
+}
+
+// Space left on the data store in GB
+func (ds *DataStore) Free() int {
+	return ds.DataStoreSize - ds.Usage
+}
+
+// Percentage of the data store in use
+func (ds *DataStore) UsagePercent() int {
+	if ds.DataStoreSize == 0 {
+		return 0
+	}
+	return ds.Usage * 100 / ds.DataStoreSize
+}
+
+func (dss DataStores) AsList() list.List {
+	var l list.List
+	for _, v := range dss {
+		l.PushBack(v)
+	}
+	return l
+}
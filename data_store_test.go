@@ -0,0 +1,50 @@
+package onapp
+
+import (
+	"testing"
+)
+
+func TestGetDataStores(t *testing.T) {
+	ts, _ := newAPIServer(t, map[string]string{
+		"GET /settings/data_stores.json": `[{"data_store":{"id":1,"label":"san1","data_store_size":1000,"usage":250,` +
+			`"data_store_type":"lvm","data_store_group_id":3,"enabled":true}},{"data_store":{"id":2,"label":"local"}}]`,
+		"GET /settings/data_stores/1.json":    `{"data_store":{"id":1,"label":"san1","identifier":"abc123"}}`,
+		"GET /settings/data_store_zones.json": `[{"data_store_group":{"id":3,"label":"SAN"}}]`,
+		"GET /settings/disks.json":            `[{"disk":{"id":10,"data_store_id":1,"disk_size":20,"virtual_machine_id":7}}]`,
+	})
+	defer ts.Close()
+	c := newAPIClient(ts)
+
+	dss, err := c.GetDataStores()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dss) != 2 || dss[0].DataStoreType != "lvm" || dss[0].DataStoreGroupID != 3 || !dss[0].Enabled {
+		t.Fatalf("Unexpected data stores: %+v", dss)
+	}
+	if dss[0].Free() != 750 || dss[0].UsagePercent() != 25 || dss[1].UsagePercent() != 0 {
+		t.Errorf("Unexpected free %d, usage %d%%, %d%%", dss[0].Free(), dss[0].UsagePercent(), dss[1].UsagePercent())
+	}
+
+	ds, err := c.GetDataStore(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Identifier != "abc123" {
+		t.Errorf("Unexpected data store: %+v", ds)
+	}
+	zones, err := c.GetDataStoreZones()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zones) != 1 || zones[0].Label != "SAN" {
+		t.Errorf("Unexpected zones: %+v", zones)
+	}
+	disks, err := c.GetDisks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(disks) != 1 || disks[0].DataStoreID != 1 || disks[0].VirtualMachineID != 7 {
+		t.Errorf("Unexpected disks: %+v", disks)
+	}
+}
@@ -1,8 +1,12 @@
 package onapp
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"sort"
 	"strconv"
+	"time"
 )
 
 type Disks []Disk
@@ -70,8 +74,35 @@ type DiskScheduleLog struct {
 	} `json:"schedule_log"`
 }
 
+// Fetches every disk on the dashboard server (requires administrative permissions)
+func (c *Client) GetDisks() (Disks, error) {
+	return c.GetDisksContext(context.Background())
+}
+
+func (c *Client) GetDisksContext(ctx context.Context) (Disks, error) {
+	data, err, _ := c.getReq(ctx, "settings/disks.json")
+	if err != nil {
+		return Disks{}, err
+	}
+	var out []map[string]Disk
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return Disks{}, err
+	}
+
+	disks := make([]Disk, len(out))
+	for i := range disks {
+		disks[i] = out[i]["disk"]
+	}
+	return disks, nil
+}
+
 func (c *Client) GetVirtualMachineDisks(vmId int) (Disks, error) {
-	data, err, _ := c.getReq("/virtual_machines/", strconv.Itoa(vmId), "/disks.json")
+	return c.GetVirtualMachineDisksContext(context.Background(), vmId)
+}
+
+func (c *Client) GetVirtualMachineDisksContext(ctx context.Context, vmId int) (Disks, error) {
+	data, err, _ := c.getReq(ctx, "virtual_machines/", strconv.Itoa(vmId), "/disks.json")
 	if err != nil {
 		return Disks{}, err
 	}
@@ -88,9 +119,127 @@ func (c *Client) GetVirtualMachineDisks(vmId int) (Disks, error) {
 	return backups, nil
 }
 
+// Parameters for adding a disk to a virtual machine, see CreateDisk. The size is in GB.
+type DiskCreate struct {
+	Label             string `json:"label,omitempty"`
+	DiskSize          int    `json:"disk_size"`
+	DataStoreID       int    `json:"data_store_id"`
+	IsSwap            bool   `json:"is_swap"`
+	FileSystem        string `json:"file_system,omitempty"`
+	MountPoint        string `json:"mount_point,omitempty"`
+	AddToLinuxFstab   bool   `json:"add_to_linux_fstab"`
+	AddToFreebsdFstab bool   `json:"add_to_freebsd_fstab"`
+	RequireFormatDisk bool   `json:"require_format_disk"`
+}
+
+// Checks the disk parameters before they're sent to the dashboard server
+func (d *DiskCreate) Validate() error {
+	if d.DiskSize < 1 {
+		return errors.New("Disk size must be at least 1GB")
+	}
+	if d.DataStoreID <= 0 {
+		return errors.New("A data store is required")
+	}
+	if d.IsSwap && (d.MountPoint != "" || d.AddToLinuxFstab || d.AddToFreebsdFstab) {
+		return errors.New("Swap disks can't have a mount point")
+	}
+	if (d.AddToLinuxFstab || d.AddToFreebsdFstab) && d.MountPoint == "" {
+		return errors.New("A mount point is required to add the disk to fstab")
+	}
+	return nil
+}
+
+// Adds a disk to a virtual machine, returning it along with its build transaction.
+// The transaction may not be valid (see Transaction.IsValid) if the dashboard hadn't queued it yet.
+func (c *Client) CreateDisk(vmId int, d DiskCreate) (Disk, Transaction, error) {
+	return c.CreateDiskContext(context.Background(), vmId, d)
+}
+
+func (c *Client) CreateDiskContext(ctx context.Context, vmId int, d DiskCreate) (Disk, Transaction, error) {
+	if err := d.Validate(); err != nil {
+		return Disk{}, Transaction{}, err
+	}
+	body, err := json.Marshal(map[string]DiskCreate{"disk": d})
+	if err != nil {
+		return Disk{}, Transaction{}, err
+	}
+	mark := c.markVmTransactions(ctx, vmId)
+	data, err, _ := c.postReq(ctx, string(body), "virtual_machines/", strconv.Itoa(vmId), "/disks.json")
+	if err != nil {
+		return Disk{}, Transaction{}, err
+	}
+	var out map[string]Disk
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return Disk{}, Transaction{}, err
+	}
+	tx, err := c.findVmTransaction(ctx, vmId, "build_disk", mark, data)
+	return out["disk"], tx, err
+}
+
+// Changes the size of a disk, in GB
+func (c *Client) ResizeDisk(vmId, diskId, size int) (Transaction, error) {
+	return c.ResizeDiskContext(context.Background(), vmId, diskId, size)
+}
+
+func (c *Client) ResizeDiskContext(ctx context.Context, vmId, diskId, size int) (Transaction, error) {
+	if size < 1 {
+		return Transaction{}, errors.New("Disk size must be at least 1GB")
+	}
+	body, err := json.Marshal(map[string]map[string]int{"disk": {"disk_size": size}})
+	if err != nil {
+		return Transaction{}, err
+	}
+	mark := c.markVmTransactions(ctx, vmId)
+	data, err, _ := c.putReq(ctx, string(body),
+		"virtual_machines/", strconv.Itoa(vmId), "/disks/", strconv.Itoa(diskId), ".json")
+	if err != nil {
+		return Transaction{}, err
+	}
+	return c.findVmTransaction(ctx, vmId, "resize_disk", mark, data)
+}
+
+// Destroys a disk along with its contents
+func (c *Client) DeleteDisk(vmId, diskId int) (Transaction, error) {
+	return c.DeleteDiskContext(context.Background(), vmId, diskId)
+}
+
+func (c *Client) DeleteDiskContext(ctx context.Context, vmId, diskId int) (Transaction, error) {
+	mark := c.markVmTransactions(ctx, vmId)
+	data, err, _ := c.deleteReq(ctx,
+		"virtual_machines/", strconv.Itoa(vmId), "/disks/", strconv.Itoa(diskId), ".json")
+	if err != nil {
+		return Transaction{}, err
+	}
+	return c.findVmTransaction(ctx, vmId, "destroy_disk", mark, data)
+}
+
+// Moves a disk to another data store
+func (c *Client) MigrateDisk(vmId, diskId, dataStoreId int) (Transaction, error) {
+	return c.MigrateDiskContext(context.Background(), vmId, diskId, dataStoreId)
+}
+
+func (c *Client) MigrateDiskContext(ctx context.Context, vmId, diskId, dataStoreId int) (Transaction, error) {
+	body, err := json.Marshal(map[string]map[string]int{"disk": {"data_store_id": dataStoreId}})
+	if err != nil {
+		return Transaction{}, err
+	}
+	mark := c.markVmTransactions(ctx, vmId)
+	data, err, _ := c.postReq(ctx, string(body),
+		"virtual_machines/", strconv.Itoa(vmId), "/disks/", strconv.Itoa(diskId), "/migrate.json")
+	if err != nil {
+		return Transaction{}, err
+	}
+	return c.findVmTransaction(ctx, vmId, "migrate_disk", mark, data)
+}
+
 func (c *Client) GetVirtualMachineDiskSchedules(vmId, diskId int) (DiskSchedules, error) {
-	data, err, _ := c.getReq(
-		"/virtual_machines/", strconv.Itoa(vmId), "/disks/",
+	return c.GetVirtualMachineDiskSchedulesContext(context.Background(), vmId, diskId)
+}
+
+func (c *Client) GetVirtualMachineDiskSchedulesContext(ctx context.Context, vmId, diskId int) (DiskSchedules, error) {
+	data, err, _ := c.getReq(ctx,
+		"virtual_machines/", strconv.Itoa(vmId), "/disks/",
 		strconv.Itoa(diskId), "/schedules.json")
 	if err != nil {
 		return DiskSchedules{}, err
@@ -107,3 +256,149 @@ func (c *Client) GetVirtualMachineDiskSchedules(vmId, diskId int) (DiskSchedules
 	}
 	return ds, nil
 }
+
+// The writable settings of an autobackup schedule, see CreateDiskSchedule.
+// Backups are taken every Duration Periods (days, weeks, months or years)
+// and kept for RotationPeriod backups.
+type DiskScheduleParams struct {
+	Action         string `json:"action"`
+	Duration       int    `json:"duration"`
+	Period         string `json:"period"`
+	RotationPeriod int    `json:"rotation_period"`
+	StartAt        string `json:"start_at,omitempty"`
+}
+
+// Checks the schedule before it's sent to the dashboard server
+func (p *DiskScheduleParams) Validate() error {
+	if p.Action != "autobackup" {
+		return errors.New("Schedule action must be autobackup")
+	}
+	switch p.Period {
+	case "days", "weeks", "months", "years":
+	default:
+		return errors.New("Schedule period must be days, weeks, months or years")
+	}
+	if p.Duration < 1 {
+		return errors.New("Schedule duration must be at least 1")
+	}
+	if p.RotationPeriod < 1 {
+		return errors.New("Schedule rotation period must be at least 1")
+	}
+	return nil
+}
+
+// The writable settings of the schedule, e.g. to copy it to another disk
+func (s *DiskSchedule) ScheduleParams() DiskScheduleParams {
+	return DiskScheduleParams{
+		Action:         s.Action,
+		Duration:       s.Duration,
+		Period:         s.Period,
+		RotationPeriod: s.RotationPeriod,
+		StartAt:        s.StartAt,
+	}
+}
+
+// How often the schedule runs, ok is false if its period isn't known.
+// Months and years are taken at their longest, so that a late run is never assumed.
+func (s *DiskSchedule) Interval() (d time.Duration, ok bool) {
+	day := 24 * time.Hour
+	switch s.Period {
+	case "days":
+		d = day
+	case "weeks":
+		d = 7 * day
+	case "months":
+		d = 31 * day
+	case "years":
+		d = 366 * day
+	default:
+		return 0, false
+	}
+	return time.Duration(s.Duration) * d, s.Duration > 0
+}
+
+type scheduleLogsByNewest []DiskScheduleLog
+
+func (l scheduleLogsByNewest) Len() int           { return len(l) }
+func (l scheduleLogsByNewest) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l scheduleLogsByNewest) Less(i, j int) bool { return l[i].Log.CreatedAt > l[j].Log.CreatedAt }
+
+// Up to n of the schedule's logs, most recent first
+func (s *DiskSchedule) LatestLogs(n int) []DiskScheduleLog {
+	logs := make([]DiskScheduleLog, len(s.ScheduleLogs))
+	copy(logs, s.ScheduleLogs)
+	sort.Sort(scheduleLogsByNewest(logs))
+	if len(logs) > n {
+		logs = logs[:n]
+	}
+	return logs
+}
+
+// Time the schedule last ran successfully, ok is false if it never has
+func (s *DiskSchedule) LastSuccess() (last time.Time, ok bool) {
+	for _, l := range s.ScheduleLogs {
+		if l.Log.Status != "complete" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, l.Log.CreatedAt)
+		if err != nil {
+			continue
+		}
+		if !ok || t.After(last) {
+			last, ok = t, true
+		}
+	}
+	return
+}
+
+// Adds an autobackup schedule to a virtual machine's disk
+func (c *Client) CreateDiskSchedule(vmId, diskId int, p DiskScheduleParams) (DiskSchedule, error) {
+	return c.CreateDiskScheduleContext(context.Background(), vmId, diskId, p)
+}
+
+func (c *Client) CreateDiskScheduleContext(ctx context.Context, vmId, diskId int, p DiskScheduleParams) (DiskSchedule, error) {
+	if err := p.Validate(); err != nil {
+		return DiskSchedule{}, err
+	}
+	body, err := json.Marshal(map[string]DiskScheduleParams{"schedule": p})
+	if err != nil {
+		return DiskSchedule{}, err
+	}
+	data, err, _ := c.postReq(ctx, string(body),
+		"virtual_machines/", strconv.Itoa(vmId), "/disks/", strconv.Itoa(diskId), "/schedules.json")
+	if err != nil {
+		return DiskSchedule{}, err
+	}
+	var out map[string]DiskSchedule
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return DiskSchedule{}, err
+	}
+	return out["schedule"], nil
+}
+
+// Changes the settings of an existing schedule
+func (c *Client) UpdateDiskSchedule(scheduleId int, p DiskScheduleParams) error {
+	return c.UpdateDiskScheduleContext(context.Background(), scheduleId, p)
+}
+
+func (c *Client) UpdateDiskScheduleContext(ctx context.Context, scheduleId int, p DiskScheduleParams) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]DiskScheduleParams{"schedule": p})
+	if err != nil {
+		return err
+	}
+	_, err, _ = c.putReq(ctx, string(body), "schedules/", strconv.Itoa(scheduleId), ".json")
+	return err
+}
+
+func (c *Client) DeleteDiskSchedule(scheduleId int) error {
+	return c.DeleteDiskScheduleContext(context.Background(), scheduleId)
+}
+
+func (c *Client) DeleteDiskScheduleContext(ctx context.Context, scheduleId int) error {
+	_, err, _ := c.deleteReq(ctx, "schedules/", strconv.Itoa(scheduleId), ".json")
+	return err
+}
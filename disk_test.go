@@ -0,0 +1,227 @@
+package onapp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetVirtualMachineDisks(t *testing.T) {
+	ts, _ := newAPIServer(t, map[string]string{
+		"GET /virtual_machines/7/disks.json": `[{"disk":{"id":2,"disk_size":20,"primary":true,"data_store_id":4}},` +
+			`{"disk":{"id":3,"disk_size":1,"is_swap":true,"data_store_id":4}}]`,
+	})
+	defer ts.Close()
+
+	disks, err := newAPIClient(ts).GetVirtualMachineDisks(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(disks) != 2 || !disks[0].Primary || disks[0].DiskSize != 20 || !disks[1].IsSwap {
+		t.Errorf("Unexpected disks: %+v", disks)
+	}
+}
+
+func TestCreateDisk(t *testing.T) {
+	ts, reqs := newAPIServer(t, map[string]string{
+		"GET /virtual_machines/7/transactions.json": queuedTransactions("build_disk"),
+		"POST /virtual_machines/7/disks.json":       `201 {"disk":{"id":5,"disk_size":10,"label":"data"}}`,
+	})
+	defer ts.Close()
+	c := newAPIClient(ts)
+
+	bad := []DiskCreate{
+		{DiskSize: 0, DataStoreID: 4},
+		{DiskSize: 10},
+		{DiskSize: 1, DataStoreID: 4, IsSwap: true, MountPoint: "/data"},
+		{DiskSize: 10, DataStoreID: 4, AddToLinuxFstab: true},
+	}
+	for _, d := range bad {
+		if _, _, err := c.CreateDisk(7, d); err == nil {
+			t.Errorf("Expected %+v to be rejected", d)
+		}
+	}
+	if len(*reqs) != 0 {
+		t.Fatalf("Invalid disks were sent: %v", *reqs)
+	}
+
+	d := DiskCreate{Label: "data", DiskSize: 10, DataStoreID: 4, MountPoint: "/data", AddToLinuxFstab: true}
+	disk, tx, err := c.CreateDisk(7, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if disk.ID != 5 || tx.Id != 12 {
+		t.Errorf("Unexpected disk #%d, transaction #%d", disk.ID, tx.Id)
+	}
+	body, _ := (*reqs)[1].Body["disk"].(map[string]interface{})
+	if body["disk_size"] != float64(10) || body["data_store_id"] != float64(4) ||
+		body["mount_point"] != "/data" || body["add_to_linux_fstab"] != true {
+		t.Errorf("Unexpected body: %v", (*reqs)[1].Body)
+	}
+}
+
+func TestResizeDisk(t *testing.T) {
+	ts, reqs := newAPIServer(t, map[string]string{
+		"GET /virtual_machines/7/transactions.json": queuedTransactions("resize_disk"),
+		"PUT /virtual_machines/7/disks/2.json":      `204 `,
+	})
+	defer ts.Close()
+	c := newAPIClient(ts)
+
+	if _, err := c.ResizeDisk(7, 2, 0); err == nil {
+		t.Error("Expected a size of 0 to be rejected")
+	}
+	tx, err := c.ResizeDisk(7, 2, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Id != 12 {
+		t.Errorf("Expected transaction #12, got #%d", tx.Id)
+	}
+	body, _ := (*reqs)[1].Body["disk"].(map[string]interface{})
+	if body["disk_size"] != float64(30) {
+		t.Errorf("Unexpected body: %v", (*reqs)[1].Body)
+	}
+}
+
+func TestDeleteDiskNotQueued(t *testing.T) {
+	ts, _ := newAPIServer(t, map[string]string{
+		"GET /virtual_machines/7/transactions.json": `[{"transaction":{"id":10,"action":"destroy_disk"}}]`,
+		"DELETE /virtual_machines/7/disks/2.json":   `204 `,
+	})
+	defer ts.Close()
+
+	tx, err := newAPIClient(ts).DeleteDisk(7, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.IsValid() {
+		t.Errorf("Expected no transaction, got the stale #%d", tx.Id)
+	}
+}
+
+func TestMigrateDisk(t *testing.T) {
+	ts, reqs := newAPIServer(t, map[string]string{
+		"GET /virtual_machines/7/transactions.json":     queuedTransactions("migrate_disk"),
+		"POST /virtual_machines/7/disks/2/migrate.json": `201 `,
+	})
+	defer ts.Close()
+
+	tx, err := newAPIClient(ts).MigrateDisk(7, 2, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Id != 12 {
+		t.Errorf("Expected transaction #12, got #%d", tx.Id)
+	}
+	body, _ := (*reqs)[1].Body["disk"].(map[string]interface{})
+	if body["data_store_id"] != float64(9) {
+		t.Errorf("Unexpected body: %v", (*reqs)[1].Body)
+	}
+}
+
+func TestGetVirtualMachineDiskSchedules(t *testing.T) {
+	ts, _ := newAPIServer(t, map[string]string{
+		"GET /virtual_machines/7/disks/2/schedules.json": `[{"schedule":{"id":4,"action":"autobackup","duration":1,` +
+			`"period":"days","rotation_period":7,"schedule_logs":[` +
+			`{"schedule_log":{"id":1,"status":"complete","created_at":"2015-03-01T01:00:00Z"}},` +
+			`{"schedule_log":{"id":3,"status":"failed","created_at":"2015-03-03T01:00:00Z"}},` +
+			`{"schedule_log":{"id":2,"status":"complete","created_at":"2015-03-02T01:00:00Z"}}]}}]`,
+	})
+	defer ts.Close()
+
+	schedules, err := newAPIClient(ts).GetVirtualMachineDiskSchedules(7, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedules) != 1 {
+		t.Fatalf("Expected 1 schedule, got %d", len(schedules))
+	}
+	s := schedules[0]
+	if p := s.ScheduleParams(); p != (DiskScheduleParams{Action: "autobackup", Duration: 1, Period: "days", RotationPeriod: 7}) {
+		t.Errorf("Unexpected params: %+v", p)
+	}
+	logs := s.LatestLogs(2)
+	if len(logs) != 2 || logs[0].Log.ID != 3 || logs[1].Log.ID != 2 {
+		t.Errorf("Unexpected latest logs: %+v", logs)
+	}
+	last, ok := s.LastSuccess()
+	if !ok || !last.Equal(time.Date(2015, 3, 2, 1, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected last success: %v %v", last, ok)
+	}
+	if _, ok := (&DiskSchedule{}).LastSuccess(); ok {
+		t.Error("A schedule without logs shouldn't have succeeded")
+	}
+}
+
+func TestDiskSchedules(t *testing.T) {
+	ts, reqs := newAPIServer(t, map[string]string{
+		"POST /virtual_machines/7/disks/2/schedules.json": `201 {"schedule":{"id":4,"action":"autobackup","period":"weeks"}}`,
+		"PUT /schedules/4.json":                           `204 `,
+		"DELETE /schedules/4.json":                        `204 `,
+	})
+	defer ts.Close()
+	c := newAPIClient(ts)
+
+	bad := []DiskScheduleParams{
+		{Action: "backup", Duration: 1, Period: "days", RotationPeriod: 1},
+		{Action: "autobackup", Duration: 1, Period: "hours", RotationPeriod: 1},
+		{Action: "autobackup", Duration: 0, Period: "days", RotationPeriod: 1},
+		{Action: "autobackup", Duration: 1, Period: "days", RotationPeriod: 0},
+	}
+	for _, p := range bad {
+		if _, err := c.CreateDiskSchedule(7, 2, p); err == nil {
+			t.Errorf("Expected %+v to be rejected", p)
+		}
+	}
+	if len(*reqs) != 0 {
+		t.Fatalf("Invalid schedules were sent: %v", *reqs)
+	}
+
+	p := DiskScheduleParams{Action: "autobackup", Duration: 1, Period: "weeks", RotationPeriod: 4}
+	s, err := c.CreateDiskSchedule(7, 2, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ID != 4 || s.Period != "weeks" {
+		t.Errorf("Unexpected schedule: %+v", s)
+	}
+	body, _ := (*reqs)[0].Body["schedule"].(map[string]interface{})
+	if body["period"] != "weeks" || body["rotation_period"] != float64(4) || body["action"] != "autobackup" {
+		t.Errorf("Unexpected body: %v", (*reqs)[0].Body)
+	}
+	if _, ok := body["start_at"]; ok {
+		t.Error("A blank start_at shouldn't be sent")
+	}
+
+	if err := c.UpdateDiskSchedule(4, p); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteDiskSchedule(4); err != nil {
+		t.Fatal(err)
+	}
+	if len(*reqs) != 3 {
+		t.Errorf("Expected 3 requests, got %d", len(*reqs))
+	}
+}
+
+func TestDiskScheduleInterval(t *testing.T) {
+	day := 24 * time.Hour
+	for _, c := range []struct {
+		duration int
+		period   string
+		expected time.Duration
+		ok       bool
+	}{
+		{1, "days", day, true},
+		{2, "weeks", 14 * day, true},
+		{1, "months", 31 * day, true},
+		{1, "years", 366 * day, true},
+		{0, "days", 0, false},
+		{1, "hours", 0, false},
+	} {
+		s := DiskSchedule{Duration: c.duration, Period: c.period}
+		if d, ok := s.Interval(); d != c.expected || ok != c.ok {
+			t.Errorf("%d %s: expected %v %v, got %v %v", c.duration, c.period, c.expected, c.ok, d, ok)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package onapp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Returned when the dashboard server responds with a non-2xx status.
+// Errors holds the messages from the OnApp `errors` body, keyed by field.
+// Messages that aren't about a particular field are keyed by "base".
+type APIError struct {
+	StatusCode int
+	Status     string
+	Method     string
+	URL        string
+	Errors     map[string][]string
+	Body       []byte
+}
+
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       body,
+		Errors:     parseAPIErrors(body),
+	}
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		e.URL = resp.Request.URL.String()
+	}
+	return e
+}
+
+// The OnApp API returns either {"errors":{"field":["msg"]}} or {"errors":["msg"]}
+func parseAPIErrors(body []byte) map[string][]string {
+	var out struct {
+		Errors json.RawMessage `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil || len(out.Errors) == 0 {
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(out.Errors, &list); err == nil {
+		return map[string][]string{"base": list}
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(out.Errors, &fields); err != nil {
+		return nil
+	}
+	errs := make(map[string][]string, len(fields))
+	for k, v := range fields {
+		switch v := v.(type) {
+		case string:
+			errs[k] = append(errs[k], v)
+		case []interface{}:
+			for _, m := range v {
+				errs[k] = append(errs[k], fmt.Sprint(m))
+			}
+		}
+	}
+	return errs
+}
+
+// All of the error messages in the response body, with the field name prefixed where relevant
+func (e *APIError) Messages() []string {
+	var keys []string
+	for k := range e.Errors {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var out []string
+	for _, k := range keys {
+		for _, m := range e.Errors[k] {
+			if k == "base" {
+				out = append(out, m)
+			} else {
+				out = append(out, k+" "+m)
+			}
+		}
+	}
+	return out
+}
+
+func (e *APIError) Error() string {
+	detail := strings.Join(e.Messages(), ", ")
+	if detail == "" {
+		detail = string(bytes.TrimSpace(e.Body))
+	}
+	return fmt.Sprintf("Bad response on '%s %s' call: HTTP %d - %s\n%s", e.Method, e.URL, e.StatusCode, e.Status, detail)
+}
+
+func hasStatus(err error, code int) bool {
+	e, ok := err.(*APIError)
+	return ok && e.StatusCode == code
+}
+
+// Reports whether err is an APIError for HTTP 404
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// Reports whether err is an APIError for HTTP 422, which OnApp uses
+// when an action isn't possible in the current state (e.g. booting a booted VM)
+func IsUnprocessable(err error) bool {
+	return hasStatus(err, 422)
+}
+
+// Reports whether err is an APIError for HTTP 401
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized)
+}
+
+// Reports whether err is an APIError for HTTP 403
+func IsForbidden(err error) bool {
+	return hasStatus(err, http.StatusForbidden)
+}
@@ -0,0 +1,174 @@
+package onapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+type FirewallRules []FirewallRule
+
+// A firewall rule on one of a virtual machine's network interfaces,
+// as according to /virtual_machines/:id/firewall_rules.json
+type FirewallRule struct {
+	Address            string       `json:"address"`
+	Command            string       `json:"command"`
+	CreatedAt          string       `json:"created_at,omitempty"`
+	ID                 int          `json:"id,omitempty"`
+	NetworkInterfaceID int          `json:"network_interface_id,omitempty"`
+	Port               FirewallPort `json:"port"`
+	Position           int          `json:"position,omitempty"`
+	Protocol           string       `json:"protocol"`
+	UpdatedAt          string       `json:"updated_at,omitempty"`
+	VirtualMachineID   int          `json:"virtual_machine_id,omitempty"`
+}
+
+// A port or port range (such as 1000:2000), which the API returns as either a number or a string
+type FirewallPort string
+
+func (p *FirewallPort) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		s = ""
+	}
+	*p = FirewallPort(strings.Trim(s, `"`))
+	return nil
+}
+
+// Checks the rule before it's sent to the dashboard server
+func (r *FirewallRule) Validate() error {
+	switch r.Command {
+	case "ACCEPT", "DROP":
+	default:
+		return errors.New("Firewall rule command must be ACCEPT or DROP")
+	}
+	switch r.Protocol {
+	case "TCP", "UDP":
+	case "ICMP":
+		if r.Port != "" {
+			return errors.New("ICMP firewall rules can't have a port")
+		}
+	default:
+		return errors.New("Firewall rule protocol must be TCP, UDP or ICMP")
+	}
+	if r.NetworkInterfaceID <= 0 {
+		return errors.New("Firewall rule needs a network interface")
+	}
+	return nil
+}
+
+func (c *Client) GetVirtualMachineFirewallRules(vmId int) (FirewallRules, error) {
+	return c.GetVirtualMachineFirewallRulesContext(context.Background(), vmId)
+}
+
+func (c *Client) GetVirtualMachineFirewallRulesContext(ctx context.Context, vmId int) (FirewallRules, error) {
+	data, err, _ := c.getReq(ctx, "virtual_machines/", strconv.Itoa(vmId), "/firewall_rules.json")
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]FirewallRule
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]FirewallRule, len(out))
+	for i := range rules {
+		rules[i] = out[i]["firewall_rule"]
+	}
+	return rules, nil
+}
+
+// Adds a rule to a virtual machine. Rules take effect once ApplyFirewallRules is called.
+func (c *Client) CreateFirewallRule(vmId int, rule FirewallRule) (FirewallRule, error) {
+	return c.CreateFirewallRuleContext(context.Background(), vmId, rule)
+}
+
+func (c *Client) CreateFirewallRuleContext(ctx context.Context, vmId int, rule FirewallRule) (FirewallRule, error) {
+	if err := rule.Validate(); err != nil {
+		return FirewallRule{}, err
+	}
+	body, err := firewallRuleBody(rule)
+	if err != nil {
+		return FirewallRule{}, err
+	}
+	data, err, _ := c.postReq(ctx, body, "virtual_machines/", strconv.Itoa(vmId), "/firewall_rules.json")
+	if err != nil {
+		return FirewallRule{}, err
+	}
+	var out map[string]FirewallRule
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return FirewallRule{}, err
+	}
+	return out["firewall_rule"], nil
+}
+
+// Changes an existing rule, identified by rule.ID
+func (c *Client) UpdateFirewallRule(vmId int, rule FirewallRule) error {
+	return c.UpdateFirewallRuleContext(context.Background(), vmId, rule)
+}
+
+func (c *Client) UpdateFirewallRuleContext(ctx context.Context, vmId int, rule FirewallRule) error {
+	if rule.ID <= 0 {
+		return errors.New("Firewall rule to update needs an id")
+	}
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	body, err := firewallRuleBody(rule)
+	if err != nil {
+		return err
+	}
+	_, err, _ = c.putReq(ctx, body, "virtual_machines/", strconv.Itoa(vmId), "/firewall_rules/", strconv.Itoa(rule.ID), ".json")
+	return err
+}
+
+func (c *Client) DeleteFirewallRule(vmId, ruleId int) error {
+	return c.DeleteFirewallRuleContext(context.Background(), vmId, ruleId)
+}
+
+func (c *Client) DeleteFirewallRuleContext(ctx context.Context, vmId, ruleId int) error {
+	_, err, _ := c.deleteReq(ctx, "virtual_machines/", strconv.Itoa(vmId), "/firewall_rules/", strconv.Itoa(ruleId), ".json")
+	return err
+}
+
+// Applies the virtual machine's firewall rules on its hypervisor
+func (c *Client) ApplyFirewallRules(vmId int) error {
+	return c.ApplyFirewallRulesContext(context.Background(), vmId)
+}
+
+func (c *Client) ApplyFirewallRulesContext(ctx context.Context, vmId int) error {
+	_, err, _ := c.postReq(ctx, "", "virtual_machines/", strconv.Itoa(vmId), "/update_firewall_rules.json")
+	return err
+}
+
+// Only the writable fields are sent
+func firewallRuleBody(rule FirewallRule) (string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"firewall_rule": map[string]interface{}{
+			"address":              rule.Address,
+			"command":              rule.Command,
+			"network_interface_id": rule.NetworkInterfaceID,
+			"port":                 rule.Port,
+			"protocol":             rule.Protocol,
+		},
+	})
+	return string(body), err
+}
+
+// A copy of the rules without the fields tied to a particular virtual machine,
+// suitable for exporting and creating on another VM.
+func (rules FirewallRules) Portable() FirewallRules {
+	out := make(FirewallRules, len(rules))
+	for i, r := range rules {
+		out[i] = FirewallRule{
+			Address:  r.Address,
+			Command:  r.Command,
+			Port:     r.Port,
+			Protocol: r.Protocol,
+		}
+	}
+	return out
+}
@@ -0,0 +1,64 @@
+package onapp
+
+import (
+	"testing"
+)
+
+func TestFirewallRules(t *testing.T) {
+	ts, reqs := newAPIServer(t, map[string]string{
+		"GET /virtual_machines/7/firewall_rules.json": `[{"firewall_rule":{"id":1,"command":"ACCEPT","protocol":"TCP",` +
+			`"port":22,"network_interface_id":2,"virtual_machine_id":7}},` +
+			`{"firewall_rule":{"id":2,"command":"DROP","protocol":"ICMP","port":null,"network_interface_id":2}}]`,
+		"POST /virtual_machines/7/firewall_rules.json":        `201 {"firewall_rule":{"id":3,"command":"ACCEPT","protocol":"UDP","port":"1000:2000"}}`,
+		"PUT /virtual_machines/7/firewall_rules/3.json":       `204 `,
+		"DELETE /virtual_machines/7/firewall_rules/3.json":    `204 `,
+		"POST /virtual_machines/7/update_firewall_rules.json": `201 `,
+	})
+	defer ts.Close()
+	c := newAPIClient(ts)
+
+	rules, err := c.GetVirtualMachineFirewallRules(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].Port != "22" || rules[1].Port != "" {
+		t.Errorf("Unexpected rules: %+v", rules)
+	}
+	portable := rules.Portable()
+	if portable[0].ID != 0 || portable[0].NetworkInterfaceID != 0 || portable[0].Port != "22" {
+		t.Errorf("Unexpected portable rule: %+v", portable[0])
+	}
+
+	rule := FirewallRule{Command: "ACCEPT", Protocol: "UDP", Port: "1000:2000", NetworkInterfaceID: 2}
+	created, err := c.CreateFirewallRule(7, rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID != 3 || created.Port != "1000:2000" {
+		t.Errorf("Unexpected created rule: %+v", created)
+	}
+	body, _ := (*reqs)[1].Body["firewall_rule"].(map[string]interface{})
+	if body["network_interface_id"] != float64(2) || body["port"] != "1000:2000" || body["protocol"] != "UDP" {
+		t.Errorf("Unexpected body: %v", (*reqs)[1].Body)
+	}
+
+	if _, err := c.CreateFirewallRule(7, FirewallRule{Command: "ACCEPT", Protocol: "TCP"}); err == nil {
+		t.Error("Expected a rule without an interface to be rejected")
+	}
+	if err := c.UpdateFirewallRule(7, rule); err == nil {
+		t.Error("Expected a rule without an id to be rejected")
+	}
+	rule.ID = 3
+	if err := c.UpdateFirewallRule(7, rule); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteFirewallRule(7, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ApplyFirewallRules(7); err != nil {
+		t.Fatal(err)
+	}
+	if len(*reqs) != 5 {
+		t.Errorf("Expected 5 requests, got %d", len(*reqs))
+	}
+}
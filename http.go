@@ -2,55 +2,81 @@ package onapp
 
 import (
 	"bytes"
-	"errors"
-	"fmt"
+	"context"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 )
 
-var cl http.Client
+func (c *Client) getReq(ctx context.Context, path ...string) ([]byte, error, int) {
+	return c.doReq(ctx, "GET", "", path...)
+}
 
-func (c *Client) getReq(path ...string) ([]byte, error, int) {
-	url := c.makeUri(path...)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err, -1
-	}
-	req.SetBasicAuth(c.apiUser, c.apiPassword)
-	resp, err := cl.Do(req)
-	if err != nil {
-		return nil, err, -1
-	}
-	return c.readResponse(resp)
+func (c *Client) postReq(ctx context.Context, body string, path ...string) ([]byte, error, int) {
+	return c.doReq(ctx, "POST", body, path...)
+}
+
+func (c *Client) putReq(ctx context.Context, body string, path ...string) ([]byte, error, int) {
+	return c.doReq(ctx, "PUT", body, path...)
+}
+
+func (c *Client) deleteReq(ctx context.Context, path ...string) ([]byte, error, int) {
+	return c.doReq(ctx, "DELETE", "", path...)
 }
 
-func (c *Client) postReq(body string, path ...string) ([]byte, error, int) {
+// Performs a request against the dashboard server, bound to ctx,
+// retrying transient failures according to the client's RetryPolicy.
+func (c *Client) doReq(ctx context.Context, method string, body string, path ...string) ([]byte, error, int) {
 	url := c.makeUri(path...)
-	req, err := http.NewRequest("POST", url, strings.NewReader(body))
-	if err != nil {
-		return nil, err, -1
+	attempts := c.retry.attempts(method)
+	for attempt := 1; ; attempt++ {
+		data, err, st, hdr := c.doReqOnce(ctx, method, url, body)
+		if attempt >= attempts || !isRetryable(err, st) || ctx.Err() != nil {
+			return data, err, st
+		}
+		wait, ok := parseRetryAfter(hdr)
+		if !ok {
+			wait = c.retry.backoff(attempt)
+		} else if max := c.retry.maxWait(); wait > max {
+			wait = max
+		}
+		if c.retry.OnRetry != nil {
+			c.retry.OnRetry(RetryEvent{method, url, attempt, st, err, wait})
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err(), -1
+		case <-time.After(wait):
+		}
 	}
-	req.SetBasicAuth(c.apiUser, c.apiPassword)
-	resp, err := cl.Do(req)
-	if err != nil {
-		return nil, err, -1
-	}
-	return c.readResponse(resp)
 }
 
-func (c *Client) deleteReq(path ...string) ([]byte, error, int) {
-	url := c.makeUri(path...)
-	req, err := http.NewRequest("DELETE", url, nil)
+// Performs a single attempt at a request, also returning the response headers
+// so that the caller can honour Retry-After.
+func (c *Client) doReqOnce(ctx context.Context, method string, url string, body string) ([]byte, error, int, http.Header) {
+	var rd io.Reader
+	if body != "" {
+		rd = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, rd)
 	if err != nil {
-		return nil, err, -1
+		return nil, err, -1, nil
 	}
+	req = req.WithContext(ctx)
 	req.SetBasicAuth(c.apiUser, c.apiPassword)
-	resp, err := cl.Do(req)
+	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set("Accept", "application/json")
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, err, -1
+		return nil, err, -1, nil
 	}
-	return c.readResponse(resp)
+	data, err, st := c.readResponse(resp)
+	return data, err, st, resp.Header
 }
 
 func (c *Client) readResponse(resp *http.Response) ([]byte, error, int) {
@@ -60,13 +86,19 @@ func (c *Client) readResponse(resp *http.Response) ([]byte, error, int) {
 		return nil, err, resp.StatusCode
 	}
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return nil,
-			errors.New(fmt.Sprintf("Bad response on '%s' call: HTTP %d - %s\n%s", resp.Request.URL, resp.StatusCode, resp.Status, data)),
-			resp.StatusCode
+		return nil, newAPIError(resp, data), resp.StatusCode
 	}
 	return data, nil, resp.StatusCode
 }
 
+// The OnApp API takes booleans in query strings as 1 or 0
+func boolParam(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
 // Concatenates the path elements together, and then to the dashboard URL.
 func (c *Client) makeUri(toConcat ...string) string {
 	buf := bytes.NewBufferString("")
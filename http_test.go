@@ -1,7 +1,19 @@
 package onapp
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func newTestClient() *Client {
@@ -29,3 +41,260 @@ func TestMakeUri(t *testing.T) {
 		t.Fail()
 	}
 }
+
+type recordingTransport struct {
+	requests []*http.Request
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests = append(t.requests, req)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestClientOptions(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != "test-agent" {
+			t.Errorf("Unexpected User-Agent: %s", r.Header.Get("User-Agent"))
+		}
+		w.Write([]byte(`{"user":{"login":"admin"}}`))
+	}))
+	defer ts.Close()
+
+	rt := &recordingTransport{}
+	c, err := NewClient(ts.URL, "user@example.org", "1234",
+		WithTransport(rt), WithUserAgent("test-agent"), WithTimeout(5*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := c.GetProfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Login != "admin" {
+		t.Errorf("Unexpected login: %s", p.Login)
+	}
+	if len(rt.requests) != 1 {
+		t.Errorf("Expected 1 request through the transport, got %d", len(rt.requests))
+	}
+	if c.httpClient.Timeout != 5*time.Second {
+		t.Errorf("Timeout wasn't applied: %v", c.httpClient.Timeout)
+	}
+}
+
+func TestContextCancel(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"user":{}}`))
+	}))
+	defer ts.Close()
+
+	c, _ := NewClient(ts.URL, "user@example.org", "1234")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.GetProfileContext(ctx); err == nil {
+		t.Error("Expected an error from a cancelled context")
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/virtual_machines/1/startup.json":
+			w.WriteHeader(422)
+			w.Write([]byte(`{"errors":{"base":["VM is already booted"]}}`))
+		case "/virtual_machines/2/startup.json":
+			w.WriteHeader(422)
+			w.Write([]byte(`{"errors":["Not enough memory"]}`))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer ts.Close()
+
+	c, _ := NewClient(ts.URL, "user@example.org", "1234")
+	err := c.VirtualMachineStartup(1)
+	if !IsUnprocessable(err) || IsNotFound(err) {
+		t.Fatalf("Expected a 422 APIError, got %v", err)
+	}
+	apiErr := err.(*APIError)
+	if apiErr.Method != "POST" || apiErr.URL != ts.URL+"/virtual_machines/1/startup.json" {
+		t.Errorf("Unexpected request details: %s %s", apiErr.Method, apiErr.URL)
+	}
+	if m := apiErr.Messages(); len(m) != 1 || m[0] != "VM is already booted" {
+		t.Errorf("Unexpected messages: %v", m)
+	}
+
+	err = c.VirtualMachineStartup(2)
+	if m := err.(*APIError).Messages(); len(m) != 1 || m[0] != "Not enough memory" {
+		t.Errorf("Unexpected messages: %v", m)
+	}
+
+	_, err = c.GetVirtualMachine(3)
+	if !IsNotFound(err) {
+		t.Errorf("Expected a 404 APIError, got %v", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var hits int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if hits < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"user":{"login":"admin"}}`))
+	}))
+	defer ts.Close()
+
+	var events []RetryEvent
+	c, _ := NewClient(ts.URL, "user@example.org", "1234", WithRetryPolicy(RetryPolicy{
+		MaxAttempts: 3,
+		OnRetry:     func(e RetryEvent) { events = append(events, e) },
+	}))
+	if _, err := c.GetProfile(); err != nil {
+		t.Fatal(err)
+	}
+	if hits != 3 || len(events) != 2 {
+		t.Fatalf("Expected 3 attempts and 2 retries, got %d and %d", hits, len(events))
+	}
+	if events[1].Attempt != 2 || events[1].StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Unexpected retry event: %+v", events[1])
+	}
+
+	// POST isn't retried unless opted in
+	hits = 0
+	if err := c.VirtualMachineReboot(1); err == nil || hits != 1 {
+		t.Errorf("Expected a single failed POST, got %d attempts (%v)", hits, err)
+	}
+}
+
+func TestRetryAfterCapped(t *testing.T) {
+	var hits int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if hits == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"user":{"login":"admin"}}`))
+	}))
+	defer ts.Close()
+
+	var waits []time.Duration
+	c, _ := NewClient(ts.URL, "user@example.org", "1234", WithRetryPolicy(RetryPolicy{
+		MaxAttempts: 2,
+		MaxBackoff:  time.Millisecond,
+		OnRetry:     func(e RetryEvent) { waits = append(waits, e.Wait) },
+	}))
+	if _, err := c.GetProfile(); err != nil {
+		t.Fatal(err)
+	}
+	if len(waits) != 1 || waits[0] != time.Millisecond {
+		t.Errorf("Expected a single wait capped at 1ms, got %v", waits)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	refused := &url.Error{Op: "Get", URL: "https://dashboard.example.org", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}
+	malformed := &url.Error{Op: "Get", URL: "https://dashboard.example.org", Err: errors.New("unsupported protocol scheme")}
+	cases := []struct {
+		err    error
+		status int
+		want   bool
+	}{
+		{refused, -1, true},
+		{&url.Error{Op: "Get", URL: "https://dashboard.example.org", Err: io.ErrUnexpectedEOF}, -1, true},
+		{malformed, -1, false},
+		{errors.New("invalid request"), -1, false},
+		{nil, http.StatusServiceUnavailable, true},
+		{nil, http.StatusInternalServerError, false},
+	}
+	for _, c := range cases {
+		if got := isRetryable(c.err, c.status); got != c.want {
+			t.Errorf("isRetryable(%v, %d) = %v, expected %v", c.err, c.status, got, c.want)
+		}
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := RetryPolicy{MinBackoff: time.Second, MaxBackoff: 4 * time.Second}
+	for i, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		d := p.backoff(i + 1)
+		if d < max/2 || d > max {
+			t.Errorf("Backoff for attempt %d out of range: %v", i+1, d)
+		}
+	}
+}
+
+// A request received by a server from newAPIServer, with its JSON body decoded
+type apiRequest struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Body   map[string]interface{}
+}
+
+// Serves canned JSON responses keyed by "METHOD /path", recording each request.
+// A response beginning with a status code and a space, e.g "422 {...}", is sent with that status.
+// A route given several responses with apiResponses sends them in turn, repeating the last.
+// Requests for anything else fail the test.
+func newAPIServer(t *testing.T, routes map[string]string) (*httptest.Server, *[]apiRequest) {
+	var reqs []apiRequest
+	served := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := apiRequest{Method: r.Method, Path: r.URL.Path, Query: r.URL.Query()}
+		if data, _ := ioutil.ReadAll(r.Body); len(data) > 0 {
+			if err := json.Unmarshal(data, &req.Body); err != nil {
+				t.Errorf("%s %s sent a body that isn't a JSON object: %s", r.Method, r.URL.Path, data)
+			}
+		}
+		reqs = append(reqs, req)
+		route := r.Method + " " + r.URL.Path
+		all, ok := routes[route]
+		if !ok {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		resps := strings.Split(all, apiResponseSep)
+		resp := resps[len(resps)-1]
+		if n := served[route]; n < len(resps) {
+			resp = resps[n]
+		}
+		served[route]++
+		status := http.StatusOK
+		if i := strings.IndexByte(resp, ' '); i == 3 {
+			if n, err := strconv.Atoi(resp[:3]); err == nil {
+				status, resp = n, resp[4:]
+			}
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(resp))
+	}))
+	return ts, &reqs
+}
+
+const apiResponseSep = "\x00"
+
+// Successive responses for one route of newAPIServer
+func apiResponses(resps ...string) string {
+	return strings.Join(resps, apiResponseSep)
+}
+
+// The VM's transactions before and after an action queued its transaction as #12,
+// with an older transaction #10 of the same action that mustn't be mistaken for it
+func queuedTransactions(action string) string {
+	return apiResponses(
+		`[{"transaction":{"id":11,"action":"reboot_virtual_machine"}},{"transaction":{"id":10,"action":"`+action+`"}}]`,
+		`[{"transaction":{"id":12,"action":"`+action+`","status":"pending"}},`+
+			`{"transaction":{"id":11,"action":"reboot_virtual_machine"}},{"transaction":{"id":10,"action":"`+action+`"}}]`,
+	)
+}
+
+// Creates a client for ts that doesn't retry, so failures show up straight away
+func newAPIClient(ts *httptest.Server) *Client {
+	c, _ := NewClient(ts.URL, "user@example.org", "1234", WithRetryPolicy(RetryPolicy{}))
+	return c
+}
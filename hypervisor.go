@@ -0,0 +1,116 @@
+package onapp
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"strconv"
+)
+
+type Hypervisors []Hypervisor
+
+// A hypervisor as according to /settings/hypervisors.json. Memory is in MB.
+type Hypervisor struct {
+	CalledInAt        string `json:"called_in_at"`
+	CpuCores          int    `json:"cpu_cores"`
+	CpuIdle           int    `json:"cpu_idle"`
+	CpuMhz            int    `json:"cpu_mhz"`
+	Cpus              int    `json:"cpus"`
+	CreatedAt         string `json:"created_at"`
+	Enabled           bool   `json:"enabled"`
+	FailureCount      int    `json:"failure_count"`
+	FreeMemory        int    `json:"free_memory"`
+	HypervisorGroupID int    `json:"hypervisor_group_id"`
+	HypervisorType    string `json:"hypervisor_type"`
+	ID                int    `json:"id"`
+	IpAddress         string `json:"ip_address"`
+	Label             string `json:"label"`
+	Locked            bool   `json:"locked"`
+	Online            bool   `json:"online"`
+	ServerType        string `json:"server_type"`
+	TotalMemory       int    `json:"total_memory"`
+	UpdatedAt         string `json:"updated_at"`
+}
+
+type HypervisorZones []HypervisorZone
+
+// A group of hypervisors as according to /settings/hypervisor_zones.json
+type HypervisorZone struct {
+	Closed          bool   `json:"closed"`
+	CreatedAt       string `json:"created_at"`
+	ID              int    `json:"id"`
+	Label           string `json:"label"`
+	LocationGroupID int    `json:"location_group_id"`
+	ServerType      string `json:"server_type"`
+	UpdatedAt       string `json:"updated_at"`
+}
+
+// Fetches the hypervisors from the dashboard server (requires administrative permissions)
+func (c *Client) GetHypervisors() (Hypervisors, error) {
+	return c.GetHypervisorsContext(context.Background())
+}
+
+func (c *Client) GetHypervisorsContext(ctx context.Context) (Hypervisors, error) {
+	data, err, _ := c.getReq(ctx, "settings/hypervisors.json")
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]Hypervisor
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return nil, err
+	}
+	hvs := make([]Hypervisor, len(out))
+	for i := range hvs {
+		hvs[i] = out[i]["hypervisor"]
+	}
+	return hvs, nil
+}
+
+// Fetches an individual hypervisor
+func (c *Client) GetHypervisor(id int) (Hypervisor, error) {
+	return c.GetHypervisorContext(context.Background(), id)
+}
+
+func (c *Client) GetHypervisorContext(ctx context.Context, id int) (Hypervisor, error) {
+	data, err, _ := c.getReq(ctx, "settings/hypervisors/", strconv.Itoa(id), ".json")
+	if err != nil {
+		return Hypervisor{}, err
+	}
+	var out map[string]Hypervisor
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return Hypervisor{}, err
+	}
+	return out["hypervisor"], nil
+}
+
+// Fetches the hypervisor zones (OnApp's hypervisor groups)
+func (c *Client) GetHypervisorZones() (HypervisorZones, error) {
+	return c.GetHypervisorZonesContext(context.Background())
+}
+
+func (c *Client) GetHypervisorZonesContext(ctx context.Context) (HypervisorZones, error) {
+	data, err, _ := c.getReq(ctx, "settings/hypervisor_zones.json")
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]HypervisorZone
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return nil, err
+	}
+	zones := make([]HypervisorZone, len(out))
+	for i := range zones {
+		zones[i] = out[i]["hypervisor_group"]
+	}
+	return zones, nil
+}
+
+func (hvs Hypervisors) AsList() list.List {
+	var l list.List
+	for _, v := range hvs {
+		l.PushBack(v)
+	}
+	return l
+}
@@ -0,0 +1,49 @@
+package onapp
+
+import (
+	"testing"
+)
+
+func TestGetHypervisors(t *testing.T) {
+	ts, _ := newAPIServer(t, map[string]string{
+		"GET /settings/hypervisors.json": `[{"hypervisor":{"id":1,"label":"hv1","online":true,"free_memory":2048,` +
+			`"total_memory":8192,"hypervisor_group_id":2}},{"hypervisor":{"id":2,"label":"hv2"}}]`,
+		"GET /settings/hypervisors/1.json":    `{"hypervisor":{"id":1,"label":"hv1","cpu_idle":90}}`,
+		"GET /settings/hypervisor_zones.json": `[{"hypervisor_group":{"id":2,"label":"Zone A","closed":true}}]`,
+	})
+	defer ts.Close()
+	c := newAPIClient(ts)
+
+	hvs, err := c.GetHypervisors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hvs) != 2 || !hvs[0].Online || hvs[0].FreeMemory != 2048 || hvs[0].HypervisorGroupID != 2 || hvs[1].Label != "hv2" {
+		t.Errorf("Unexpected hypervisors: %+v", hvs)
+	}
+	hv, err := c.GetHypervisor(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hv.ID != 1 || hv.CpuIdle != 90 {
+		t.Errorf("Unexpected hypervisor: %+v", hv)
+	}
+	zones, err := c.GetHypervisorZones()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zones) != 1 || zones[0].Label != "Zone A" || !zones[0].Closed {
+		t.Errorf("Unexpected zones: %+v", zones)
+	}
+}
+
+func TestGetHypervisorsForbidden(t *testing.T) {
+	ts, _ := newAPIServer(t, map[string]string{
+		"GET /settings/hypervisors.json": `403 {"errors":["You do not have permissions for this action"]}`,
+	})
+	defer ts.Close()
+
+	if _, err := newAPIClient(ts).GetHypervisors(); !IsForbidden(err) {
+		t.Errorf("Expected a 403 APIError, got %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package onapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+)
+
+type NetworkInterfaces []NetworkInterface
+
+// A virtual machine's network interface as according to /virtual_machines/:id/network_interfaces.json
+type NetworkInterface struct {
+	Connected        bool   `json:"connected"`
+	CreatedAt        string `json:"created_at"`
+	ID               int    `json:"id"`
+	Identifier       string `json:"identifier"`
+	Label            string `json:"label"`
+	MacAddress       string `json:"mac_address"`
+	NetworkJoinID    int    `json:"network_join_id"`
+	Primary          bool   `json:"primary"`
+	RateLimit        int    `json:"rate_limit"`
+	UpdatedAt        string `json:"updated_at"`
+	VirtualMachineID int    `json:"virtual_machine_id"`
+}
+
+type IpAddressJoins []IpAddressJoin
+
+// The assignment of an IP address to a network interface,
+// as according to /virtual_machines/:id/ip_addresses.json
+type IpAddressJoin struct {
+	CreatedAt          string    `json:"created_at"`
+	ID                 int       `json:"id"`
+	IpAddress          IpAddress `json:"ip_address"`
+	IpAddressID        int       `json:"ip_address_id"`
+	NetworkInterfaceID int       `json:"network_interface_id"`
+	UpdatedAt          string    `json:"updated_at"`
+}
+
+type Networks []Network
+
+// A network that IP addresses are allocated from, as according to /settings/networks.json
+type Network struct {
+	CreatedAt      string `json:"created_at"`
+	ID             int    `json:"id"`
+	Identifier     string `json:"identifier"`
+	Label          string `json:"label"`
+	NetworkGroupID int    `json:"network_group_id"`
+	UpdatedAt      string `json:"updated_at"`
+	Vlan           int    `json:"vlan"`
+}
+
+var ErrNoFreeIpAddress = errors.New("No free IP addresses left in that network")
+
+func (c *Client) GetVirtualMachineNetworkInterfaces(vmId int) (NetworkInterfaces, error) {
+	return c.GetVirtualMachineNetworkInterfacesContext(context.Background(), vmId)
+}
+
+func (c *Client) GetVirtualMachineNetworkInterfacesContext(ctx context.Context, vmId int) (NetworkInterfaces, error) {
+	data, err, _ := c.getReq(ctx, "virtual_machines/", strconv.Itoa(vmId), "/network_interfaces.json")
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]NetworkInterface
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return nil, err
+	}
+	nics := make([]NetworkInterface, len(out))
+	for i := range nics {
+		nics[i] = out[i]["network_interface"]
+	}
+	return nics, nil
+}
+
+// Fetches the IP addresses assigned to a virtual machine, along with their network interfaces
+func (c *Client) GetVirtualMachineIpAddressJoins(vmId int) (IpAddressJoins, error) {
+	return c.GetVirtualMachineIpAddressJoinsContext(context.Background(), vmId)
+}
+
+func (c *Client) GetVirtualMachineIpAddressJoinsContext(ctx context.Context, vmId int) (IpAddressJoins, error) {
+	data, err, _ := c.getReq(ctx, "virtual_machines/", strconv.Itoa(vmId), "/ip_addresses.json")
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]IpAddressJoin
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return nil, err
+	}
+	joins := make([]IpAddressJoin, len(out))
+	for i := range joins {
+		joins[i] = out[i]["ip_address_join"]
+	}
+	return joins, nil
+}
+
+// Assigns an IP address from the pool to one of the virtual machine's network interfaces.
+// The VM's network needs rebuilding (see RebuildNetwork) before it is configured inside the VM.
+func (c *Client) AssignIpAddress(vmId, interfaceId, ipAddressId int) (IpAddressJoin, error) {
+	return c.AssignIpAddressContext(context.Background(), vmId, interfaceId, ipAddressId)
+}
+
+func (c *Client) AssignIpAddressContext(ctx context.Context, vmId, interfaceId, ipAddressId int) (IpAddressJoin, error) {
+	body, err := json.Marshal(map[string]map[string]int{
+		"ip_address": {"network_interface_id": interfaceId, "ip_address_id": ipAddressId},
+	})
+	if err != nil {
+		return IpAddressJoin{}, err
+	}
+	data, err, _ := c.postReq(ctx, string(body), "virtual_machines/", strconv.Itoa(vmId), "/ip_addresses.json")
+	if err != nil {
+		return IpAddressJoin{}, err
+	}
+	var out map[string]IpAddressJoin
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return IpAddressJoin{}, err
+	}
+	return out["ip_address_join"], nil
+}
+
+// Assigns the first free IP address in a network to one of the virtual machine's network interfaces,
+// returning ErrNoFreeIpAddress if the network is exhausted.
+func (c *Client) AssignFreeIpAddress(vmId, interfaceId, networkId int) (IpAddressJoin, error) {
+	return c.AssignFreeIpAddressContext(context.Background(), vmId, interfaceId, networkId)
+}
+
+func (c *Client) AssignFreeIpAddressContext(ctx context.Context, vmId, interfaceId, networkId int) (IpAddressJoin, error) {
+	ips, err := c.GetNetworkIpAddressesContext(ctx, networkId)
+	if err != nil {
+		return IpAddressJoin{}, err
+	}
+	for _, ip := range ips {
+		if ip.Free {
+			return c.AssignIpAddressContext(ctx, vmId, interfaceId, ip.Id)
+		}
+	}
+	return IpAddressJoin{}, ErrNoFreeIpAddress
+}
+
+// Removes an IP address from a virtual machine, by the id of its IpAddressJoin
+func (c *Client) RemoveIpAddress(vmId, joinId int) error {
+	return c.RemoveIpAddressContext(context.Background(), vmId, joinId)
+}
+
+func (c *Client) RemoveIpAddressContext(ctx context.Context, vmId, joinId int) error {
+	_, err, _ := c.deleteReq(ctx, "virtual_machines/", strconv.Itoa(vmId), "/ip_addresses/", strconv.Itoa(joinId), ".json")
+	return err
+}
+
+// Reconfigures the network inside the virtual machine to match its assigned IP addresses.
+// The dashboard will reboot the VM to do so.
+func (c *Client) RebuildNetwork(vmId int) error {
+	return c.RebuildNetworkContext(context.Background(), vmId)
+}
+
+func (c *Client) RebuildNetworkContext(ctx context.Context, vmId int) error {
+	_, err, _ := c.postReq(ctx, "", "virtual_machines/", strconv.Itoa(vmId), "/rebuild_network.json")
+	return err
+}
+
+// Fetches the networks that IP addresses can be allocated from
+func (c *Client) GetNetworks() (Networks, error) {
+	return c.GetNetworksContext(context.Background())
+}
+
+func (c *Client) GetNetworksContext(ctx context.Context) (Networks, error) {
+	data, err, _ := c.getReq(ctx, "settings/networks.json")
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]Network
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return nil, err
+	}
+	nets := make([]Network, len(out))
+	for i := range nets {
+		nets[i] = out[i]["network"]
+	}
+	return nets, nil
+}
+
+// Fetches the pool of IP addresses in a network, both free and assigned
+func (c *Client) GetNetworkIpAddresses(networkId int) ([]IpAddress, error) {
+	return c.GetNetworkIpAddressesContext(context.Background(), networkId)
+}
+
+func (c *Client) GetNetworkIpAddressesContext(ctx context.Context, networkId int) ([]IpAddress, error) {
+	data, err, _ := c.getReq(ctx, "settings/networks/", strconv.Itoa(networkId), "/ip_addresses.json")
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]IpAddress
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]IpAddress, len(out))
+	for i := range ips {
+		ips[i] = out[i]["ip_address"]
+	}
+	return ips, nil
+}
+
+func (vm *VirtualMachine) GetNetworkInterfaces() (NetworkInterfaces, error) {
+	return vm.client.GetVirtualMachineNetworkInterfaces(vm.Id)
+}
+
+func (vm *VirtualMachine) GetNetworkInterfacesContext(ctx context.Context) (NetworkInterfaces, error) {
+	return vm.client.GetVirtualMachineNetworkInterfacesContext(ctx, vm.Id)
+}
+
+// The primary network interface, or the first if none is marked primary
+func (nics NetworkInterfaces) Primary() (NetworkInterface, bool) {
+	for _, n := range nics {
+		if n.Primary {
+			return n, true
+		}
+	}
+	if len(nics) > 0 {
+		return nics[0], true
+	}
+	return NetworkInterface{}, false
+}
@@ -0,0 +1,90 @@
+package onapp
+
+import (
+	"testing"
+)
+
+func TestGetVirtualMachineNetworking(t *testing.T) {
+	ts, _ := newAPIServer(t, map[string]string{
+		"GET /virtual_machines/7/network_interfaces.json": `[{"network_interface":{"id":3,"label":"eth1"}},` +
+			`{"network_interface":{"id":2,"label":"eth0","primary":true,"rate_limit":100}}]`,
+		"GET /virtual_machines/7/ip_addresses.json": `[{"ip_address_join":{"id":40,"network_interface_id":2,` +
+			`"ip_address":{"id":9,"address":"10.0.0.9"}}}]`,
+		"GET /settings/networks.json": `[{"network":{"id":5,"label":"Public","vlan":100}}]`,
+	})
+	defer ts.Close()
+	c := newAPIClient(ts)
+
+	nics, err := c.GetVirtualMachineNetworkInterfaces(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if primary, ok := nics.Primary(); !ok || primary.ID != 2 || primary.RateLimit != 100 {
+		t.Errorf("Unexpected primary interface: %+v", primary)
+	}
+	if _, ok := (NetworkInterfaces{}).Primary(); ok {
+		t.Error("No interfaces shouldn't have a primary")
+	}
+
+	joins, err := c.GetVirtualMachineIpAddressJoins(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(joins) != 1 || joins[0].NetworkInterfaceID != 2 || joins[0].IpAddress.Address != "10.0.0.9" {
+		t.Errorf("Unexpected joins: %+v", joins)
+	}
+	nets, err := c.GetNetworks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nets) != 1 || nets[0].Vlan != 100 {
+		t.Errorf("Unexpected networks: %+v", nets)
+	}
+}
+
+func TestAssignFreeIpAddress(t *testing.T) {
+	ts, reqs := newAPIServer(t, map[string]string{
+		"GET /settings/networks/5/ip_addresses.json": `[{"ip_address":{"id":8,"address":"10.0.0.8","free":false}},` +
+			`{"ip_address":{"id":9,"address":"10.0.0.9","free":true}}]`,
+		"POST /virtual_machines/7/ip_addresses.json": `201 {"ip_address_join":{"id":40,"ip_address_id":9,"network_interface_id":2}}`,
+		"GET /settings/networks/6/ip_addresses.json": `[{"ip_address":{"id":1,"free":false}}]`,
+	})
+	defer ts.Close()
+	c := newAPIClient(ts)
+
+	join, err := c.AssignFreeIpAddress(7, 2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if join.ID != 40 || join.IpAddressID != 9 {
+		t.Errorf("Unexpected join: %+v", join)
+	}
+	post := (*reqs)[1]
+	ip, _ := post.Body["ip_address"].(map[string]interface{})
+	if ip["ip_address_id"] != float64(9) || ip["network_interface_id"] != float64(2) {
+		t.Errorf("Unexpected body: %v", post.Body)
+	}
+
+	if _, err := c.AssignFreeIpAddress(7, 2, 6); err != ErrNoFreeIpAddress {
+		t.Errorf("Expected ErrNoFreeIpAddress, got %v", err)
+	}
+}
+
+func TestRemoveIpAddressAndRebuildNetwork(t *testing.T) {
+	ts, reqs := newAPIServer(t, map[string]string{
+		"DELETE /virtual_machines/7/ip_addresses/40.json": `204 `,
+		"POST /virtual_machines/7/rebuild_network.json":   `201 `,
+	})
+	defer ts.Close()
+	c := newAPIClient(ts)
+
+	if err := c.RemoveIpAddress(7, 40); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RebuildNetwork(7); err != nil {
+		t.Fatal(err)
+	}
+	if len(*reqs) != 2 {
+		t.Errorf("Expected 2 requests, got %d", len(*reqs))
+	}
+}
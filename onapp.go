@@ -4,31 +4,106 @@ import (
 	"encoding/json"
 	"errors"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"os/user"
 	"path/filepath"
+	"time"
 )
 
+// The User-Agent sent to the dashboard server unless WithUserAgent is used
+const DefaultUserAgent = "github.com/alexzorin/onapp"
+
 type Client struct {
 	Server      string
 	apiUser     string
 	apiPassword string
+	httpClient  *http.Client
+	userAgent   string
+	retry       RetryPolicy
+	// Between polls of transactions being waited for
+	pollInterval time.Duration
+}
+
+// Optional configuration for NewClient and NewClientFromSystem
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	httpClient   *http.Client
+	transport    http.RoundTripper
+	timeout      time.Duration
+	userAgent    string
+	retry        RetryPolicy
+	pollInterval time.Duration
+}
+
+// Limits the time taken by each request to the dashboard server, including reading the response.
+func WithTimeout(d time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.timeout = d
+	}
+}
+
+// Sends requests using a copy of hc rather than a fresh http.Client.
+// WithTimeout and WithTransport apply on top of the copy, hc itself is never modified.
+func WithHTTPClient(hc *http.Client) ClientOption {
+	return func(o *clientOptions) {
+		o.httpClient = hc
+	}
+}
+
+// Sends requests through rt, e.g. to add tracing or logging middleware.
+func WithTransport(rt http.RoundTripper) ClientOption {
+	return func(o *clientOptions) {
+		o.transport = rt
+	}
+}
+
+// Overrides the User-Agent header sent with each request (DefaultUserAgent).
+func WithUserAgent(ua string) ClientOption {
+	return func(o *clientOptions) {
+		o.userAgent = ua
+	}
 }
 
 // Creates a new API client with the specified hostname, email address and API key.
 // The hostname needs to be the DNS-resolvable hostname of the dashboard server (such as dashboard.example.org).
-func NewClient(hostname string, email string, apiKey string) (*Client, error) {
+func NewClient(hostname string, email string, apiKey string, opts ...ClientOption) (*Client, error) {
 	if hostname == "" || email == "" || apiKey == "" {
 		return nil, errors.New("Invalid parameters to NewClient")
 	}
 
-	cl := &Client{hostname, email, apiKey}
+	o := clientOptions{userAgent: DefaultUserAgent, retry: DefaultRetryPolicy, pollInterval: DefaultPollInterval}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	hc := &http.Client{}
+	if o.httpClient != nil {
+		*hc = *o.httpClient
+	}
+	if o.transport != nil {
+		hc.Transport = o.transport
+	}
+	if o.timeout > 0 {
+		hc.Timeout = o.timeout
+	}
+
+	cl := &Client{
+		Server:       hostname,
+		apiUser:      email,
+		apiPassword:  apiKey,
+		httpClient:   hc,
+		userAgent:    o.userAgent,
+		retry:        o.retry,
+		pollInterval: o.pollInterval,
+	}
 	return cl, nil
 }
 
 // Creates a new API client using the file (defaults to ~/.onapp) or the OS environment variables
 // Environment variables take precedence.
-func NewClientFromSystem(file string) (*Client, error) {
+func NewClientFromSystem(file string, opts ...ClientOption) (*Client, error) {
 	var out struct {
 		Server  string `json:"Server"`
 		ApiUser string `json:"ApiUser"`
@@ -72,5 +147,5 @@ func NewClientFromSystem(file string) (*Client, error) {
 		out.ApiKey = p
 	}
 
-	return NewClient(out.Server, out.ApiUser, out.ApiKey)
+	return NewClient(out.Server, out.ApiUser, out.ApiKey, opts...)
 }
@@ -0,0 +1,81 @@
+package onapp
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// The page size used when walking list endpoints if ListOptions.PerPage isn't set
+const DefaultPerPage = 100
+
+// Selects a page of a list endpoint. Pages are numbered from 1.
+// Zero values leave the choice to the dashboard server.
+type ListOptions struct {
+	Page    int
+	PerPage int
+}
+
+// Query string for the options, including the leading '?', or "" if there's nothing to send
+func (o ListOptions) query() string {
+	v := url.Values{}
+	if o.Page > 0 {
+		v.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.PerPage > 0 {
+		v.Set("per_page", strconv.Itoa(o.PerPage))
+	}
+	if len(v) == 0 {
+		return ""
+	}
+	return "?" + v.Encode()
+}
+
+// Fetches a single page, storing the items in the typed pager that owns the closure.
+// Returns the number of items and the id of the first one.
+type pageFetcher func(ctx context.Context, opts ListOptions) (int, int, error)
+
+// Walks a list endpoint one page at a time, stopping at an empty page. A short page
+// isn't taken as the last, since the dashboard may cap per_page below what was asked for.
+type pager struct {
+	ctx       context.Context
+	opts      ListOptions
+	fetch     pageFetcher
+	done      bool
+	err       error
+	lastFirst int
+}
+
+func newPager(ctx context.Context, opts ListOptions, fetch pageFetcher) pager {
+	if opts.Page < 1 {
+		opts.Page = 1
+	}
+	if opts.PerPage < 1 {
+		opts.PerPage = DefaultPerPage
+	}
+	return pager{ctx: ctx, opts: opts, fetch: fetch}
+}
+
+func (p *pager) next() bool {
+	if p.done || p.err != nil {
+		return false
+	}
+	n, first, err := p.fetch(p.ctx, p.opts)
+	if err != nil {
+		p.err = err
+		return false
+	}
+	// Some endpoints ignore paging and return everything each time
+	if n == 0 || (p.opts.Page > 1 && first == p.lastFirst) {
+		p.done = true
+		return false
+	}
+	p.lastFirst = first
+	p.opts.Page++
+	return true
+}
+
+// The error that stopped iteration, if any
+func (p *pager) Err() error {
+	return p.err
+}
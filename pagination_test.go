@@ -0,0 +1,88 @@
+package onapp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Serves n virtual machines, honouring page and per_page (up to maxPerPage if not 0)
+// unless ignorePaging is set
+func newVmListServer(n int, maxPerPage int, ignorePaging bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		if maxPerPage > 0 && perPage > maxPerPage {
+			perPage = maxPerPage
+		}
+		from, to := 1, n
+		if !ignorePaging && page > 0 && perPage > 0 {
+			from = (page-1)*perPage + 1
+			if to > page*perPage {
+				to = page * perPage
+			}
+		}
+		var items []string
+		for i := from; i <= to; i++ {
+			items = append(items, fmt.Sprintf(`{"virtual_machine":{"id":%d}}`, i))
+		}
+		w.Write([]byte("[" + strings.Join(items, ",") + "]"))
+	}))
+}
+
+func TestListVirtualMachines(t *testing.T) {
+	ts := newVmListServer(5, 0, false)
+	defer ts.Close()
+	c, _ := NewClient(ts.URL, "user@example.org", "1234")
+
+	p := c.ListVirtualMachines(ListOptions{PerPage: 2})
+	var pages []int
+	for p.Next() {
+		pages = append(pages, len(p.Page()))
+	}
+	if p.Err() != nil {
+		t.Fatal(p.Err())
+	}
+	if len(pages) != 3 || pages[0] != 2 || pages[2] != 1 {
+		t.Errorf("Unexpected page sizes: %v", pages)
+	}
+
+	vms, err := c.GetVirtualMachinesPage(ListOptions{Page: 2, PerPage: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vms) != 2 || vms[0].Id != 3 {
+		t.Errorf("Unexpected page: %+v", vms)
+	}
+}
+
+func TestListVirtualMachinesIgnoredPaging(t *testing.T) {
+	ts := newVmListServer(3, 0, true)
+	defer ts.Close()
+	c, _ := NewClient(ts.URL, "user@example.org", "1234")
+
+	vms, err := c.ListVirtualMachines(ListOptions{PerPage: 2}).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vms) != 3 {
+		t.Errorf("Expected 3 VMs, got %d", len(vms))
+	}
+}
+
+func TestListVirtualMachinesCappedPerPage(t *testing.T) {
+	ts := newVmListServer(7, 3, false)
+	defer ts.Close()
+	c, _ := NewClient(ts.URL, "user@example.org", "1234")
+
+	vms, err := c.ListVirtualMachines(ListOptions{PerPage: 100}).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vms) != 7 {
+		t.Errorf("Expected all 7 VMs across the capped pages, got %d", len(vms))
+	}
+}
@@ -1,6 +1,7 @@
 package onapp
 
 import (
+	"context"
 	"encoding/json"
 )
 
@@ -15,7 +16,12 @@ type Profile struct {
 
 // Fetches the user profile from the dashboard server
 func (c *Client) GetProfile() (*Profile, error) {
-	data, err, _ := c.getReq("profile.json")
+	return c.GetProfileContext(context.Background())
+}
+
+// Fetches the user profile from the dashboard server, bound to ctx
+func (c *Client) GetProfileContext(ctx context.Context) (*Profile, error) {
+	data, err, _ := c.getReq(ctx, "profile.json")
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,142 @@
+package onapp
+
+import (
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Controls how requests that fail with a transient error (failing to reach the
+// server, HTTP 429, 502, 503 or 504) are retried. See WithRetryPolicy.
+type RetryPolicy struct {
+	// Total attempts per request, including the first. 1 or less disables retries.
+	MaxAttempts int
+	// The backoff before the first retry, doubling for each one after it up to MaxBackoff.
+	// Half of each backoff is randomised to spread out retries from many clients.
+	// A Retry-After sent by the server is honoured, but never beyond MaxBackoff
+	// (or DefaultRetryPolicy's if unset).
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// HTTP methods that may be retried, only GET if empty. Add POST or DELETE
+	// only if repeating those calls against your dashboard is safe.
+	Methods []string
+	// If set, called before waiting for each retry
+	OnRetry func(RetryEvent)
+}
+
+// Describes a failed attempt that is about to be retried
+type RetryEvent struct {
+	Method     string
+	URL        string
+	Attempt    int   // The attempt that failed, starting at 1
+	StatusCode int   // -1 if there was no response
+	Err        error // The error returned by the failed attempt
+	Wait       time.Duration
+}
+
+// The policy used by NewClient unless WithRetryPolicy is passed
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  500 * time.Millisecond,
+	MaxBackoff:  10 * time.Second,
+	Methods:     []string{"GET"},
+}
+
+// Replaces DefaultRetryPolicy for requests made by the client
+func WithRetryPolicy(p RetryPolicy) ClientOption {
+	return func(o *clientOptions) {
+		o.retry = p
+	}
+}
+
+// Number of attempts to make for a request using method
+func (p *RetryPolicy) attempts(method string) int {
+	if p.MaxAttempts <= 1 {
+		return 1
+	}
+	methods := p.Methods
+	if len(methods) == 0 {
+		methods = []string{"GET"}
+	}
+	for _, m := range methods {
+		if m == method {
+			return p.MaxAttempts
+		}
+	}
+	return 1
+}
+
+// Wait before retrying after the given (1-indexed) failed attempt
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.MinBackoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := int64(d / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// Longest wait before a retry, whatever the server asks for with Retry-After
+func (p *RetryPolicy) maxWait() time.Duration {
+	if p.MaxBackoff > 0 {
+		return p.MaxBackoff
+	}
+	return DefaultRetryPolicy.MaxBackoff
+}
+
+func isRetryable(err error, status int) bool {
+	switch status {
+	case -1:
+		return isTransportError(err)
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Whether err came from failing to reach the server or read its response,
+// rather than e.g. a malformed URL or a TLS certificate that won't ever verify
+func isTransportError(err error) bool {
+	if ue, ok := err.(*url.Error); ok {
+		err = ue.Err
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	switch e := err.(type) {
+	case *net.OpError:
+		return true
+	case net.Error:
+		return e.Timeout() || e.Temporary()
+	}
+	return false
+}
+
+// Retry-After is either a number of seconds or an HTTP date
+func parseRetryAfter(h http.Header) (time.Duration, bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := t.Sub(time.Now())
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
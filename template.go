@@ -0,0 +1,124 @@
+package onapp
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"strconv"
+)
+
+type Templates []Template
+
+// An OS template that virtual machines are built from, as according to /templates.json
+type Template struct {
+	AllowResizeWithoutReboot bool     `json:"allow_resize_without_reboot"`
+	AllowedHotMigrate        bool     `json:"allowed_hot_migrate"`
+	AllowedSwap              bool     `json:"allowed_swap"`
+	CreatedAt                string   `json:"created_at"`
+	FileName                 string   `json:"file_name"`
+	ID                       int      `json:"id"`
+	Label                    string   `json:"label"`
+	MinDiskSize              int      `json:"min_disk_size"`
+	MinMemorySize            int      `json:"min_memory_size"`
+	OperatingSystem          string   `json:"operating_system"`
+	OperatingSystemArch      string   `json:"operating_system_arch"`
+	OperatingSystemDistro    string   `json:"operating_system_distro"`
+	OperatingSystemEdition   string   `json:"operating_system_edition"`
+	State                    string   `json:"state"`
+	UpdatedAt                string   `json:"updated_at"`
+	UserID                   int      `json:"user_id"`
+	Version                  string   `json:"version"`
+	Virtualization           []string `json:"virtualization"`
+}
+
+type TemplateGroups []TemplateGroup
+
+// A group of templates as according to /settings/image_template_groups.json
+type TemplateGroup struct {
+	CreatedAt         string `json:"created_at"`
+	HypervisorGroupID int    `json:"hypervisor_group_id"`
+	ID                int    `json:"id"`
+	Label             string `json:"label"`
+	ParentID          int    `json:"parent_id"`
+	UpdatedAt         string `json:"updated_at"`
+}
+
+// Fetches the templates available for building virtual machines
+func (c *Client) GetTemplates() (Templates, error) {
+	return c.GetTemplatesContext(context.Background())
+}
+
+func (c *Client) GetTemplatesContext(ctx context.Context) (Templates, error) {
+	data, err, _ := c.getReq(ctx, "templates.json")
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]Template
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return nil, err
+	}
+	tpls := make([]Template, len(out))
+	for i := range tpls {
+		tpls[i] = out[i]["image_template"]
+	}
+	return tpls, nil
+}
+
+// Fetches an individual template
+func (c *Client) GetTemplate(id int) (Template, error) {
+	return c.GetTemplateContext(context.Background(), id)
+}
+
+func (c *Client) GetTemplateContext(ctx context.Context, id int) (Template, error) {
+	data, err, _ := c.getReq(ctx, "templates/", strconv.Itoa(id), ".json")
+	if err != nil {
+		return Template{}, err
+	}
+	var out map[string]Template
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return Template{}, err
+	}
+	return out["image_template"], nil
+}
+
+// Fetches the groups that templates are organised into
+func (c *Client) GetTemplateGroups() (TemplateGroups, error) {
+	return c.GetTemplateGroupsContext(context.Background())
+}
+
+func (c *Client) GetTemplateGroupsContext(ctx context.Context) (TemplateGroups, error) {
+	data, err, _ := c.getReq(ctx, "settings/image_template_groups.json")
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]TemplateGroup
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]TemplateGroup, len(out))
+	for i := range groups {
+		groups[i] = out[i]["image_template_group"]
+	}
+	return groups, nil
+}
+
+// Whether the template can be used on hypervisors of the given type (e.g. xen, kvm)
+func (t *Template) SupportsVirtualization(hvType string) bool {
+	for _, v := range t.Virtualization {
+		if v == hvType {
+			return true
+		}
+	}
+	return false
+}
+
+func (tpls Templates) AsList() list.List {
+	var l list.List
+	for _, v := range tpls {
+		l.PushBack(v)
+	}
+	return l
+}
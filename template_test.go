@@ -0,0 +1,46 @@
+package onapp
+
+import (
+	"testing"
+)
+
+func TestGetTemplates(t *testing.T) {
+	ts, reqs := newAPIServer(t, map[string]string{
+		"GET /templates.json": `[{"image_template":{"id":3,"label":"Ubuntu 14.04 x64","min_disk_size":5,` +
+			`"operating_system":"linux","virtualization":["xen","kvm"]}},{"image_template":{"id":4,"label":"CentOS 7"}}]`,
+		"GET /templates/3.json":                    `{"image_template":{"id":3,"label":"Ubuntu 14.04 x64","allowed_hot_migrate":true}}`,
+		"GET /settings/image_template_groups.json": `[{"image_template_group":{"id":1,"label":"Linux"}}]`,
+	})
+	defer ts.Close()
+	c := newAPIClient(ts)
+
+	tpls, err := c.GetTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpls) != 2 || tpls[0].ID != 3 || tpls[0].MinDiskSize != 5 || tpls[1].Label != "CentOS 7" {
+		t.Errorf("Unexpected templates: %+v", tpls)
+	}
+	if !tpls[0].SupportsVirtualization("kvm") || tpls[0].SupportsVirtualization("vmware") {
+		t.Errorf("Unexpected virtualization support: %v", tpls[0].Virtualization)
+	}
+
+	tpl, err := c.GetTemplate(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tpl.ID != 3 || !tpl.AllowedHotMigrate {
+		t.Errorf("Unexpected template: %+v", tpl)
+	}
+
+	groups, err := c.GetTemplateGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0].Label != "Linux" {
+		t.Errorf("Unexpected template groups: %+v", groups)
+	}
+	if len(*reqs) != 3 {
+		t.Errorf("Expected 3 requests, got %d", len(*reqs))
+	}
+}
@@ -1,9 +1,13 @@
 package onapp
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/alexzorin/onapp/log"
+	"sort"
+	"strconv"
 	"time"
 )
 
@@ -21,18 +25,96 @@ type Transaction struct {
 	StartedAt  string `json:"started_at"`
 	UpdatedAt  string `json:"updated_at"`
 	Dependent  int    `json:"dependent_transaction_id"`
+	// Only returned when fetching a single transaction, see GetTransaction
+	LogOutput string `json:"log_output"`
 }
 
+var ErrTransactionNotPending = errors.New("Only pending transactions can be cancelled")
+
+// Fetches every transaction from the dashboard server, walking all pages
 func (c *Client) GetTransactions() (Transactions, error) {
-	return c.getTransactions(0)
+	return c.GetTransactionsContext(context.Background())
+}
+
+func (c *Client) GetTransactionsContext(ctx context.Context) (Transactions, error) {
+	return c.ListTransactionsContext(ctx, ListOptions{}).All()
+}
+
+// Fetches a single page of transactions, most recent first
+func (c *Client) GetTransactionsPage(opts ListOptions) (Transactions, error) {
+	return c.GetTransactionsPageContext(context.Background(), opts)
+}
+
+func (c *Client) GetTransactionsPageContext(ctx context.Context, opts ListOptions) (Transactions, error) {
+	return c.getTransactions(ctx, 0, opts)
+}
+
+// Iterates over pages of transactions, see ListTransactions
+type TransactionPager struct {
+	pager
+	page Transactions
+}
+
+// Returns a pager over all transactions, most recent first, starting at opts.Page
+func (c *Client) ListTransactions(opts ListOptions) *TransactionPager {
+	return c.ListTransactionsContext(context.Background(), opts)
 }
 
-func (c *Client) getTransactions(vmId int) (Transactions, error) {
+func (c *Client) ListTransactionsContext(ctx context.Context, opts ListOptions) *TransactionPager {
+	return c.listTransactions(ctx, 0, opts)
+}
+
+// Returns a pager over the transactions of a single virtual machine, most recent first
+func (c *Client) VirtualMachineListTransactions(vmId int, opts ListOptions) *TransactionPager {
+	return c.VirtualMachineListTransactionsContext(context.Background(), vmId, opts)
+}
+
+func (c *Client) VirtualMachineListTransactionsContext(ctx context.Context, vmId int, opts ListOptions) *TransactionPager {
+	return c.listTransactions(ctx, vmId, opts)
+}
+
+func (c *Client) listTransactions(ctx context.Context, vmId int, opts ListOptions) *TransactionPager {
+	p := &TransactionPager{}
+	p.pager = newPager(ctx, opts, func(ctx context.Context, opts ListOptions) (int, int, error) {
+		txs, err := c.getTransactions(ctx, vmId, opts)
+		if err != nil {
+			return 0, 0, err
+		}
+		p.page = txs
+		if len(txs) == 0 {
+			return 0, 0, nil
+		}
+		return len(txs), txs[0].Id, nil
+	})
+	return p
+}
+
+// Fetches the next page, returning false when there are no more or an error occurred
+func (p *TransactionPager) Next() bool {
+	p.page = nil
+	return p.next()
+}
+
+// The transactions on the current page
+func (p *TransactionPager) Page() Transactions {
+	return p.page
+}
+
+// Walks the remaining pages and returns their transactions together
+func (p *TransactionPager) All() (Transactions, error) {
+	txs := Transactions{}
+	for p.Next() {
+		txs = append(txs, p.Page()...)
+	}
+	return txs, p.Err()
+}
+
+func (c *Client) getTransactions(ctx context.Context, vmId int, opts ListOptions) (Transactions, error) {
 	path := "transactions.json"
 	if vmId != 0 {
 		path = fmt.Sprintf("virtual_machines/%d/transactions.json", vmId)
 	}
-	data, err, _ := c.getReq(path)
+	data, err, _ := c.getReq(ctx, path, opts.query())
 	if err != nil {
 		return nil, err
 	}
@@ -48,11 +130,128 @@ func (c *Client) getTransactions(vmId int) (Transactions, error) {
 	return txs, nil
 }
 
+// Fetches a single transaction, including its log output
+func (c *Client) GetTransaction(id int) (Transaction, error) {
+	return c.GetTransactionContext(context.Background(), id)
+}
+
+func (c *Client) GetTransactionContext(ctx context.Context, id int) (Transaction, error) {
+	data, err, _ := c.getReq(ctx, "transactions/", strconv.Itoa(id), ".json")
+	if err != nil {
+		return Transaction{}, err
+	}
+	var out map[string]Transaction
+	if err := json.Unmarshal(data, &out); err != nil {
+		return Transaction{}, err
+	}
+	tx := out["transaction"]
+	tx.client = c
+	return tx, nil
+}
+
+// Cancels a transaction that hasn't started yet, returning ErrTransactionNotPending otherwise
+func (c *Client) CancelTransaction(id int) error {
+	return c.CancelTransactionContext(context.Background(), id)
+}
+
+func (c *Client) CancelTransactionContext(ctx context.Context, id int) error {
+	tx, err := c.GetTransactionContext(ctx, id)
+	if err != nil {
+		return err
+	}
+	if tx.Status != "pending" {
+		return ErrTransactionNotPending
+	}
+	_, err, _ = c.postReq(ctx, "", "transactions/", strconv.Itoa(id), "/cancel.json")
+	return err
+}
+
+// What was known about a virtual machine's transactions just before an action was requested,
+// so that the action's transaction can be told apart from earlier ones with the same action.
+type vmTransactionMark struct {
+	// The highest transaction id on the VM, only used if listed is set
+	lastId int
+	listed bool
+	// When the action was requested, used if the transactions couldn't be listed
+	at time.Time
+}
+
+// Records the newest transaction on a virtual machine, to be called before the action is requested.
+// A failure to list the transactions isn't fatal, findVmTransaction falls back to the creation time.
+func (c *Client) markVmTransactions(ctx context.Context, vmId int) vmTransactionMark {
+	// created_at only has a resolution of seconds
+	mark := vmTransactionMark{at: time.Now().Truncate(time.Second)}
+	txns, err := c.VirtualMachineGetTransactionsContext(ctx, vmId)
+	if err != nil {
+		return mark
+	}
+	mark.listed = true
+	for _, t := range txns {
+		if t.Id > mark.lastId {
+			mark.lastId = t.Id
+		}
+	}
+	return mark
+}
+
+// Whether the transaction was queued after the mark was taken
+func (m vmTransactionMark) precedes(t Transaction) bool {
+	if m.listed {
+		return t.Id > m.lastId
+	}
+	created, err := t.CreatedAtTime()
+	return err == nil && !created.Before(m.at)
+}
+
+// Finds the transaction queued for an action on a virtual machine, preferring the one in the
+// action's response (if the dashboard included it) over the newest matching one after mark.
+// The returned transaction isn't valid (see IsValid) if there isn't one.
+func (c *Client) findVmTransaction(ctx context.Context, vmId int, action string, mark vmTransactionMark, resp []byte) (Transaction, error) {
+	if tx, ok := responseTransaction(resp); ok {
+		tx.client = c
+		return tx, nil
+	}
+	txns, err := c.VirtualMachineGetTransactionsContext(ctx, vmId)
+	if err != nil {
+		return Transaction{}, err
+	}
+	for _, t := range txns {
+		if t.Action == action && mark.precedes(t) {
+			return t, nil
+		}
+	}
+	return Transaction{}, nil
+}
+
+// Some actions respond with the transaction they queued, either in full or as transaction_id
+func responseTransaction(data []byte) (Transaction, bool) {
+	var out struct {
+		Transaction   Transaction `json:"transaction"`
+		TransactionId int         `json:"transaction_id"`
+	}
+	if len(data) == 0 || json.Unmarshal(data, &out) != nil {
+		return Transaction{}, false
+	}
+	if out.Transaction.IsValid() {
+		return out.Transaction, true
+	}
+	if out.TransactionId > 0 {
+		return Transaction{Id: out.TransactionId}, true
+	}
+	return Transaction{}, false
+}
+
 func (t *Transaction) IsValid() bool {
 	return t.Id > 0
 }
 
 func (t *Transaction) StatusColored() string {
+	return t.StatusColoredWidth(0)
+}
+
+// The status right-aligned to width, then coloured. Padding the coloured string instead
+// would count the colour codes towards the width and misalign columns.
+func (t *Transaction) StatusColoredWidth(width int) string {
 	color := log.YELLOW
 	switch t.Status {
 	case "complete":
@@ -60,9 +259,81 @@ func (t *Transaction) StatusColored() string {
 	case "failed":
 		color = log.RED
 	}
-	return log.ColorString(t.Status, color)
+	return log.ColorString(fmt.Sprintf("%*s", width, t.Status), color)
 }
 
 func (tx *Transaction) CreatedAtTime() (time.Time, error) {
 	return time.Parse(time.RFC3339, tx.CreatedAt)
 }
+
+func (tx *Transaction) StartedAtTime() (time.Time, error) {
+	return time.Parse(time.RFC3339, tx.StartedAt)
+}
+
+// Time taken from StartedAt to UpdatedAt (when it finished), or so far if it's still
+// running (or stuck). ok is false if the transaction hasn't started.
+func (tx *Transaction) Duration() (d time.Duration, ok bool) {
+	started, err := tx.StartedAtTime()
+	if err != nil {
+		return 0, false
+	}
+	if !tx.IsFinished() {
+		return time.Since(started), true
+	}
+	updated, err := time.Parse(time.RFC3339, tx.UpdatedAt)
+	if err != nil {
+		return 0, false
+	}
+	return updated.Sub(started), true
+}
+
+// A transaction along with the transactions that wait on it (see Transaction.Dependent)
+type TransactionNode struct {
+	Transaction
+	Dependents []*TransactionNode
+}
+
+type transactionsById Transactions
+
+func (t transactionsById) Len() int           { return len(t) }
+func (t transactionsById) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t transactionsById) Less(i, j int) bool { return t[i].Id < t[j].Id }
+
+// Groups the transactions into dependency chains. Each root is a transaction that
+// doesn't depend on another in txs, most recent first; dependents are in the order they were created.
+func (txs Transactions) Chains() []*TransactionNode {
+	sorted := make(Transactions, len(txs))
+	copy(sorted, txs)
+	sort.Sort(transactionsById(sorted))
+
+	nodes := make(map[int]*TransactionNode, len(sorted))
+	for _, tx := range sorted {
+		nodes[tx.Id] = &TransactionNode{Transaction: tx}
+	}
+	var roots []*TransactionNode
+	for i := len(sorted) - 1; i >= 0; i-- {
+		n := nodes[sorted[i].Id]
+		parent, ok := nodes[n.Dependent]
+		if !ok || inDependencyCycle(nodes, n) {
+			roots = append(roots, n)
+			continue
+		}
+		parent.Dependents = append([]*TransactionNode{n}, parent.Dependents...)
+	}
+	return roots
+}
+
+// Whether following Dependent from n leads back to n, which shouldn't happen but would hide the chain
+func inDependencyCycle(nodes map[int]*TransactionNode, n *TransactionNode) bool {
+	for cur, steps := n, 0; steps <= len(nodes); steps++ {
+		next, ok := nodes[cur.Dependent]
+		if !ok {
+			return false
+		}
+		if next == n {
+			return true
+		}
+		cur = next
+	}
+	return false
+}
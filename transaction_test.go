@@ -0,0 +1,221 @@
+package onapp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Serves transaction #5 with each of statuses in turn, repeating the last
+func transactionServer(t *testing.T, statuses ...string) *httptest.Server {
+	var polls int
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/transactions/5.json" {
+			t.Errorf("Unexpected request for %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		status := statuses[len(statuses)-1]
+		if polls < len(statuses) {
+			status = statuses[polls]
+		}
+		polls++
+		fmt.Fprintf(w, `{"transaction":{"id":5,"action":"reboot_virtual_machine","status":"%s"}}`, status)
+	}))
+}
+
+func TestWaitForTransactionUpdates(t *testing.T) {
+	ts := transactionServer(t, "pending", "pending", "running", "complete")
+	defer ts.Close()
+
+	c, _ := NewClient(ts.URL, "user@example.org", "1234", WithPollInterval(time.Millisecond))
+	updates := make(chan Transaction, 10)
+	tx, err := c.WaitForTransactionUpdates(context.Background(), 5, updates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Status != "complete" {
+		t.Errorf("Expected a complete transaction, got %s", tx.Status)
+	}
+	var seen []string
+	for u := range updates {
+		seen = append(seen, u.Status)
+	}
+	if fmt.Sprint(seen) != "[pending running complete]" {
+		t.Errorf("Unexpected updates: %v", seen)
+	}
+}
+
+func TestWaitForTransactionFailed(t *testing.T) {
+	ts := transactionServer(t, "running", "failed")
+	defer ts.Close()
+
+	c, _ := NewClient(ts.URL, "user@example.org", "1234", WithPollInterval(time.Millisecond))
+	_, err := c.WaitForTransaction(context.Background(), 5)
+	txErr, ok := err.(*TransactionError)
+	if !ok {
+		t.Fatalf("Expected a *TransactionError, got %v", err)
+	}
+	if txErr.Transaction.Id != 5 || txErr.Transaction.Status != "failed" {
+		t.Errorf("Unexpected transaction in error: %+v", txErr.Transaction)
+	}
+}
+
+func TestWaitForTransactionContext(t *testing.T) {
+	ts := transactionServer(t, "running")
+	defer ts.Close()
+
+	c, _ := NewClient(ts.URL, "user@example.org", "1234", WithPollInterval(time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.WaitForTransaction(ctx, 5); err != context.DeadlineExceeded {
+		t.Errorf("Expected the deadline to be exceeded, got %v", err)
+	}
+}
+
+func TestTransactionChains(t *testing.T) {
+	// Most recent first, as returned by the API: a build chain 10 <- 11 <- 12
+	// with 13 also waiting on 10, an unrelated reboot 20, and 30 whose
+	// dependency is on an earlier page
+	txs := Transactions{
+		{Id: 30, Dependent: 5},
+		{Id: 20},
+		{Id: 13, Dependent: 10},
+		{Id: 12, Dependent: 11},
+		{Id: 11, Dependent: 10},
+		{Id: 10},
+	}
+	var render func(n *TransactionNode) string
+	render = func(n *TransactionNode) string {
+		s := fmt.Sprint(n.Id)
+		for _, d := range n.Dependents {
+			s += "(" + render(d) + ")"
+		}
+		return s
+	}
+	var got []string
+	for _, n := range txs.Chains() {
+		got = append(got, render(n))
+	}
+	if fmt.Sprint(got) != "[30 20 10(11(12))(13)]" {
+		t.Errorf("Unexpected chains: %v", got)
+	}
+}
+
+func TestTransactionDuration(t *testing.T) {
+	tx := Transaction{Status: "complete", StartedAt: "2015-03-01T10:00:00+10:00", UpdatedAt: "2015-03-01T10:02:30+10:00"}
+	if d, ok := tx.Duration(); !ok || d != 150*time.Second {
+		t.Errorf("Unexpected duration: %v, %v", d, ok)
+	}
+	// A running transaction has taken as long as it's been going, whenever it last changed
+	running := Transaction{Status: "running", StartedAt: time.Now().Add(-time.Hour).Format(time.RFC3339), UpdatedAt: tx.UpdatedAt}
+	if d, ok := running.Duration(); !ok || d < time.Hour || d > time.Hour+time.Minute {
+		t.Errorf("Unexpected running duration: %v, %v", d, ok)
+	}
+	pending := Transaction{UpdatedAt: "2015-03-01T10:02:30+10:00"}
+	if _, ok := pending.Duration(); ok {
+		t.Error("A transaction that hasn't started shouldn't have a duration")
+	}
+}
+
+func TestWithPollIntervalClamped(t *testing.T) {
+	for _, d := range []time.Duration{0, -time.Second} {
+		c, _ := NewClient("example.org", "user@example.org", "1234", WithPollInterval(d))
+		if c.pollInterval != DefaultPollInterval {
+			t.Errorf("WithPollInterval(%v) gave an interval of %v", d, c.pollInterval)
+		}
+	}
+}
+
+func TestVirtualMachineWaitForAction(t *testing.T) {
+	created := time.Now().Add(-time.Minute).Format(time.RFC3339)
+	ts, _ := newAPIServer(t, map[string]string{
+		"GET /virtual_machines/7/transactions.json": apiResponses(
+			`[{"transaction":{"id":10,"action":"reboot_virtual_machine","status":"complete","created_at":"`+created+`"}}]`,
+			`[{"transaction":{"id":11,"action":"reboot_virtual_machine","status":"pending"}}]`,
+		),
+		"GET /transactions/11.json": `{"transaction":{"id":11,"action":"reboot_virtual_machine","status":"complete"}}`,
+	})
+	defer ts.Close()
+	c, _ := NewClient(ts.URL, "user@example.org", "1234", WithPollInterval(time.Millisecond))
+
+	// The old finished transaction is skipped until the new one appears
+	tx, err := c.VirtualMachineWaitForAction(context.Background(), 7, "reboot_virtual_machine", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Id != 11 {
+		t.Errorf("Expected transaction #11, got #%d", tx.Id)
+	}
+}
+
+func TestVirtualMachineWaitForActionNotQueued(t *testing.T) {
+	defer func(w time.Duration) { actionWindow = w }(actionWindow)
+	actionWindow = 20 * time.Millisecond
+	ts, _ := newAPIServer(t, map[string]string{
+		"GET /virtual_machines/7/transactions.json": `[]`,
+	})
+	defer ts.Close()
+	c, _ := NewClient(ts.URL, "user@example.org", "1234", WithPollInterval(time.Millisecond))
+
+	updates := make(chan Transaction, 1)
+	if _, err := c.VirtualMachineWaitForAction(context.Background(), 7, "reboot_virtual_machine", updates); err != ErrActionNotQueued {
+		t.Errorf("Expected ErrActionNotQueued, got %v", err)
+	}
+	if _, ok := <-updates; ok {
+		t.Error("Expected updates to be closed")
+	}
+}
+
+func TestGetTransaction(t *testing.T) {
+	ts, _ := newAPIServer(t, map[string]string{
+		"GET /transactions/5.json": `{"transaction":{"id":5,"action":"take_backup","status":"failed",` +
+			`"parent_id":7,"parent_type":"VirtualMachine","log_output":"Disk is locked\nGiving up"}}`,
+	})
+	defer ts.Close()
+
+	tx, err := newAPIClient(ts).GetTransaction(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Id != 5 || tx.Parent != 7 || tx.ParentType != "VirtualMachine" || tx.LogOutput != "Disk is locked\nGiving up" {
+		t.Errorf("Unexpected transaction: %+v", tx)
+	}
+}
+
+func TestCancelTransaction(t *testing.T) {
+	ts, reqs := newAPIServer(t, map[string]string{
+		"GET /transactions/5.json":         `{"transaction":{"id":5,"status":"pending"}}`,
+		"POST /transactions/5/cancel.json": `201 `,
+		"GET /transactions/6.json":         `{"transaction":{"id":6,"status":"running"}}`,
+	})
+	defer ts.Close()
+	c := newAPIClient(ts)
+
+	if err := c.CancelTransaction(5); err != nil {
+		t.Fatal(err)
+	}
+	if len(*reqs) != 2 || (*reqs)[1].Method != "POST" {
+		t.Errorf("Expected the transaction to be cancelled: %v", *reqs)
+	}
+
+	*reqs = nil
+	if err := c.CancelTransaction(6); err != ErrTransactionNotPending {
+		t.Errorf("Expected ErrTransactionNotPending, got %v", err)
+	}
+	if len(*reqs) != 1 {
+		t.Errorf("A running transaction shouldn't be cancelled: %v", *reqs)
+	}
+}
+
+func TestStatusColoredWidth(t *testing.T) {
+	tx := Transaction{Status: "complete"}
+	padded := tx.StatusColoredWidth(10)
+	if !strings.Contains(padded, "  complete") || strings.Contains(padded, "   complete") {
+		t.Errorf("Expected the status to be padded to 10 before colouring: %q", padded)
+	}
+}
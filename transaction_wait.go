@@ -0,0 +1,145 @@
+package onapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// How often transactions are polled while waiting, unless WithPollInterval is used
+const DefaultPollInterval = 5 * time.Second
+
+// How long before WaitForAction is called a finished transaction may have been
+// created and still be taken as the one being waited for, and how long after
+// it's called the transaction has to appear
+var actionWindow = 30 * time.Second
+
+// Returned by WaitForAction when no transaction with the action appears in time
+var ErrActionNotQueued = errors.New("No transaction for the action was queued")
+
+// Sets how often transactions are polled by WaitForTransaction and WaitForAction.
+// Intervals that aren't positive leave DefaultPollInterval in place.
+func WithPollInterval(d time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		if d > 0 {
+			o.pollInterval = d
+		}
+	}
+}
+
+// Returned when a transaction being waited for fails or is cancelled
+type TransactionError struct {
+	Transaction Transaction
+}
+
+func (e *TransactionError) Error() string {
+	return fmt.Sprintf("Transaction #%d (%s) %s", e.Transaction.Id, e.Transaction.Action, e.Transaction.Status)
+}
+
+// Whether the transaction has finished, successfully or not
+func (t *Transaction) IsFinished() bool {
+	switch t.Status {
+	case "complete", "failed", "cancelled":
+		return true
+	}
+	return false
+}
+
+// Polls a transaction until it completes, returning a *TransactionError if it
+// fails or is cancelled, or ctx's error if ctx is done first.
+func (c *Client) WaitForTransaction(ctx context.Context, id int) (Transaction, error) {
+	return c.WaitForTransactionUpdates(ctx, id, nil)
+}
+
+// As WaitForTransaction, additionally sending the transaction on updates (if not nil)
+// whenever its status changes. updates is closed once waiting ends.
+func (c *Client) WaitForTransactionUpdates(ctx context.Context, id int, updates chan<- Transaction) (Transaction, error) {
+	if updates != nil {
+		defer close(updates)
+	}
+	var last string
+	for {
+		tx, err := c.GetTransactionContext(ctx, id)
+		if ctx.Err() != nil {
+			return tx, ctx.Err()
+		}
+		if err != nil {
+			return tx, err
+		}
+		if tx.Status != last {
+			last = tx.Status
+			if updates != nil {
+				select {
+				case updates <- tx:
+				case <-ctx.Done():
+					return tx, ctx.Err()
+				}
+			}
+		}
+		if tx.IsFinished() {
+			if tx.Status != "complete" {
+				return tx, &TransactionError{tx}
+			}
+			return tx, nil
+		}
+		select {
+		case <-time.After(c.pollInterval):
+		case <-ctx.Done():
+			return tx, ctx.Err()
+		}
+	}
+}
+
+// Waits for the virtual machine's transaction with the given action (e.g. "reboot_virtual_machine")
+// to complete, as WaitForTransactionUpdates. The transaction is the newest with that action
+// that is pending or running, or that finished after being created shortly before this was called,
+// polling until one appears. ErrActionNotQueued is returned if none has appeared shortly after.
+func (c *Client) VirtualMachineWaitForAction(ctx context.Context, vmId int, action string, updates chan<- Transaction) (Transaction, error) {
+	now := time.Now()
+	since, deadline := now.Add(-actionWindow), now.Add(actionWindow)
+	for {
+		txns, err := c.VirtualMachineGetTransactionsContext(ctx, vmId)
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			if updates != nil {
+				close(updates)
+			}
+			return Transaction{}, err
+		}
+		for _, tx := range txns {
+			if tx.Action != action {
+				continue
+			}
+			if created, err := tx.CreatedAtTime(); !tx.IsFinished() || (err == nil && created.After(since)) {
+				return c.WaitForTransactionUpdates(ctx, tx.Id, updates)
+			}
+			// Only the newest transaction with the action is considered
+			break
+		}
+		if time.Now().After(deadline) {
+			if updates != nil {
+				close(updates)
+			}
+			return Transaction{}, ErrActionNotQueued
+		}
+		select {
+		case <-time.After(c.pollInterval):
+		case <-ctx.Done():
+			if updates != nil {
+				close(updates)
+			}
+			return Transaction{}, ctx.Err()
+		}
+	}
+}
+
+func (vm *VirtualMachine) WaitForAction(ctx context.Context, action string) (Transaction, error) {
+	return vm.client.VirtualMachineWaitForAction(ctx, vm.Id, action, nil)
+}
+
+func (vm *VirtualMachine) WaitForActionUpdates(ctx context.Context, action string, updates chan<- Transaction) (Transaction, error) {
+	return vm.client.VirtualMachineWaitForAction(ctx, vm.Id, action, updates)
+}
@@ -0,0 +1,282 @@
+package onapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+)
+
+type Users []User
+
+// A control panel user as according to /users.json
+type User struct {
+	ActivatedAt   string `json:"activated_at"`
+	ApiKey        string `json:"api_key,omitempty"`
+	BillingPlanID int    `json:"billing_plan_id"`
+	CreatedAt     string `json:"created_at"`
+	DeletedAt     string `json:"deleted_at"`
+	Email         string `json:"email"`
+	FirstName     string `json:"first_name"`
+	ID            int    `json:"id"`
+	LastName      string `json:"last_name"`
+	Login         string `json:"login"`
+	Roles         []struct {
+		Role Role `json:"role"`
+	} `json:"roles"`
+	Status      string `json:"status"`
+	SuspendAt   string `json:"suspend_at"`
+	TimeZone    string `json:"time_zone"`
+	UpdatedAt   string `json:"updated_at"`
+	UsedCpus    int    `json:"used_cpus"`
+	UsedMemory  int    `json:"used_memory"`
+	UserGroupID int    `json:"user_group_id"`
+}
+
+type UserGroups []UserGroup
+
+// A group of users as according to /user_groups.json
+type UserGroup struct {
+	CreatedAt  string `json:"created_at"`
+	ID         int    `json:"id"`
+	Identifier string `json:"identifier"`
+	Label      string `json:"label"`
+	UpdatedAt  string `json:"updated_at"`
+}
+
+type Roles []Role
+
+// A set of permissions as according to /roles.json
+type Role struct {
+	CreatedAt  string `json:"created_at"`
+	ID         int    `json:"id"`
+	Identifier string `json:"identifier"`
+	Label      string `json:"label"`
+	UpdatedAt  string `json:"updated_at"`
+}
+
+// The parameters of a new user. Login, Email and Password are required.
+type UserCreate struct {
+	BillingPlanID int    `json:"billing_plan_id,omitempty"`
+	Email         string `json:"email"`
+	FirstName     string `json:"first_name,omitempty"`
+	LastName      string `json:"last_name,omitempty"`
+	Login         string `json:"login"`
+	Password      string `json:"password"`
+	RoleIDs       []int  `json:"role_ids,omitempty"`
+	UserGroupID   int    `json:"user_group_id,omitempty"`
+}
+
+func (u *UserCreate) Validate() error {
+	if u.Login == "" {
+		return errors.New("A login is required")
+	}
+	if u.Email == "" {
+		return errors.New("An email address is required")
+	}
+	if len(u.Password) < 6 {
+		return errors.New("The password must be at least 6 characters")
+	}
+	return nil
+}
+
+// Fetches the users visible to the API user, walking all pages (requires administrative permissions)
+func (c *Client) GetUsers() (Users, error) {
+	return c.GetUsersContext(context.Background())
+}
+
+func (c *Client) GetUsersContext(ctx context.Context) (Users, error) {
+	return c.ListUsersContext(ctx, ListOptions{}).All()
+}
+
+// Iterates over pages of users, see ListUsers
+type UserPager struct {
+	pager
+	page Users
+}
+
+// Returns a pager over the users visible to the API user, starting at opts.Page
+func (c *Client) ListUsers(opts ListOptions) *UserPager {
+	return c.ListUsersContext(context.Background(), opts)
+}
+
+func (c *Client) ListUsersContext(ctx context.Context, opts ListOptions) *UserPager {
+	p := &UserPager{}
+	p.pager = newPager(ctx, opts, func(ctx context.Context, opts ListOptions) (int, int, error) {
+		users, err := c.getUsers(ctx, opts)
+		if err != nil {
+			return 0, 0, err
+		}
+		p.page = users
+		if len(users) == 0 {
+			return 0, 0, nil
+		}
+		return len(users), users[0].ID, nil
+	})
+	return p
+}
+
+// Fetches the next page, returning false when there are no more or an error occurred
+func (p *UserPager) Next() bool {
+	p.page = nil
+	return p.next()
+}
+
+// The users on the current page
+func (p *UserPager) Page() Users {
+	return p.page
+}
+
+// Walks the remaining pages and returns their users together
+func (p *UserPager) All() (Users, error) {
+	users := Users{}
+	for p.Next() {
+		users = append(users, p.Page()...)
+	}
+	return users, p.Err()
+}
+
+func (c *Client) getUsers(ctx context.Context, opts ListOptions) (Users, error) {
+	data, err, _ := c.getReq(ctx, "users.json", opts.query())
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]User
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]User, len(out))
+	for i := range users {
+		users[i] = out[i]["user"]
+	}
+	return users, nil
+}
+
+// Fetches an individual user
+func (c *Client) GetUser(id int) (User, error) {
+	return c.GetUserContext(context.Background(), id)
+}
+
+func (c *Client) GetUserContext(ctx context.Context, id int) (User, error) {
+	data, err, _ := c.getReq(ctx, "users/", strconv.Itoa(id), ".json")
+	if err != nil {
+		return User{}, err
+	}
+	return unmarshalUser(data)
+}
+
+// Creates a new user
+func (c *Client) CreateUser(u UserCreate) (User, error) {
+	return c.CreateUserContext(context.Background(), u)
+}
+
+func (c *Client) CreateUserContext(ctx context.Context, u UserCreate) (User, error) {
+	if err := u.Validate(); err != nil {
+		return User{}, err
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"user": struct {
+			UserCreate
+			PasswordConfirmation string `json:"password_confirmation"`
+		}{u, u.Password},
+	})
+	if err != nil {
+		return User{}, err
+	}
+	data, err, _ := c.postReq(ctx, string(body), "users.json")
+	if err != nil {
+		return User{}, err
+	}
+	return unmarshalUser(data)
+}
+
+// Suspends a user, stopping their virtual machines
+func (c *Client) SuspendUser(id int) error {
+	return c.SuspendUserContext(context.Background(), id)
+}
+
+func (c *Client) SuspendUserContext(ctx context.Context, id int) error {
+	_, err, _ := c.postReq(ctx, "", "users/", strconv.Itoa(id), "/suspend.json")
+	return err
+}
+
+// Deletes a user. Unless force is set, this fails while the user
+// still owns virtual machines.
+func (c *Client) DeleteUser(id int, force bool) error {
+	return c.DeleteUserContext(context.Background(), id, force)
+}
+
+func (c *Client) DeleteUserContext(ctx context.Context, id int, force bool) error {
+	_, err, _ := c.deleteReq(ctx, "users/", strconv.Itoa(id), ".json?force=", boolParam(force))
+	return err
+}
+
+// Replaces a user's API key, returning the new one
+func (c *Client) GenerateUserApiKey(id int) (string, error) {
+	return c.GenerateUserApiKeyContext(context.Background(), id)
+}
+
+func (c *Client) GenerateUserApiKeyContext(ctx context.Context, id int) (string, error) {
+	data, err, _ := c.postReq(ctx, "", "users/", strconv.Itoa(id), "/make_new_api_key.json")
+	if err != nil {
+		return "", err
+	}
+	u, err := unmarshalUser(data)
+	if err != nil {
+		return "", err
+	}
+	return u.ApiKey, nil
+}
+
+// Fetches the user groups
+func (c *Client) GetUserGroups() (UserGroups, error) {
+	return c.GetUserGroupsContext(context.Background())
+}
+
+func (c *Client) GetUserGroupsContext(ctx context.Context) (UserGroups, error) {
+	data, err, _ := c.getReq(ctx, "user_groups.json")
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]UserGroup
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]UserGroup, len(out))
+	for i := range groups {
+		groups[i] = out[i]["user_group"]
+	}
+	return groups, nil
+}
+
+// Fetches the roles that may be assigned to users
+func (c *Client) GetRoles() (Roles, error) {
+	return c.GetRolesContext(context.Background())
+}
+
+func (c *Client) GetRolesContext(ctx context.Context) (Roles, error) {
+	data, err, _ := c.getReq(ctx, "roles.json")
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]Role
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return nil, err
+	}
+	roles := make([]Role, len(out))
+	for i := range roles {
+		roles[i] = out[i]["role"]
+	}
+	return roles, nil
+}
+
+func unmarshalUser(data []byte) (User, error) {
+	var out map[string]User
+	if err := json.Unmarshal(data, &out); err != nil {
+		return User{}, err
+	}
+	return out["user"], nil
+}
@@ -0,0 +1,69 @@
+package onapp
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestGetUsers(t *testing.T) {
+	ts, reqs := newAPIServer(t, map[string]string{
+		"GET /users.json": apiResponses(
+			`[{"user":{"id":1,"login":"admin","roles":[{"role":{"id":1,"label":"Administrator"}}]}}]`,
+			`[{"user":{"id":2,"login":"alice","user_group_id":3}}]`,
+			`[]`,
+		),
+	})
+	defer ts.Close()
+
+	users, err := newAPIClient(ts).GetUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[0].Roles[0].Role.Label != "Administrator" || users[1].UserGroupID != 3 {
+		t.Errorf("Unexpected users: %+v", users)
+	}
+	for i, r := range *reqs {
+		if page := r.Query.Get("page"); page != strconv.Itoa(i+1) {
+			t.Errorf("Request %d was for page %s", i+1, page)
+		}
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	ts, reqs := newAPIServer(t, map[string]string{
+		"POST /users.json":                    `201 {"user":{"id":5,"login":"bob"}}`,
+		"POST /users/5/make_new_api_key.json": `{"user":{"id":5,"api_key":"abcd"}}`,
+		"POST /users/5/suspend.json":          `{"user":{"id":5}}`,
+		"DELETE /users/5.json":                `204 `,
+	})
+	defer ts.Close()
+	c := newAPIClient(ts)
+
+	if _, err := c.CreateUser(UserCreate{Login: "bob", Email: "bob@example.org", Password: "12345"}); err == nil {
+		t.Error("Expected a short password to be rejected")
+	}
+	u, err := c.CreateUser(UserCreate{Login: "bob", Email: "bob@example.org", Password: "123456", RoleIDs: []int{2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.ID != 5 {
+		t.Errorf("Unexpected user: %+v", u)
+	}
+	body, _ := (*reqs)[0].Body["user"].(map[string]interface{})
+	if body["login"] != "bob" || body["password_confirmation"] != "123456" || len(body["role_ids"].([]interface{})) != 1 {
+		t.Errorf("Unexpected body: %v", (*reqs)[0].Body)
+	}
+
+	if key, err := c.GenerateUserApiKey(5); err != nil || key != "abcd" {
+		t.Errorf("Unexpected API key %q: %v", key, err)
+	}
+	if err := c.SuspendUser(5); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteUser(5, true); err != nil {
+		t.Fatal(err)
+	}
+	if force := (*reqs)[3].Query.Get("force"); force != "1" {
+		t.Errorf("Expected a forced delete, got force=%s", force)
+	}
+}
@@ -2,8 +2,11 @@ package onapp
 
 import (
 	"container/list"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net/url"
 	"strconv"
 
 	"github.com/alexzorin/onapp/log"
@@ -26,21 +29,32 @@ type VirtualMachine struct {
 	CpuShares      int                    `json:"cpu_shares"`
 	Memory         int                    `json:"memory"`
 	Template       string                 `json:"template_label"`
+	TemplateId     int                    `json:"template_id"`
 	User           int                    `json:"user_id"`
 	Locked         bool                   `json:"locked"`
 	RootPassword   string                 `json:"initial_root_password"`
 	IpAddressesRaw []map[string]IpAddress `json:"ip_addresses"`
 	VncPassword    string                 `json:"remote_access_password"`
 	AdminNote      string                 `json:"admin_note"`
+	// Whether CPUs and memory can be changed while the VM is booted
+	AllowResizeWithoutReboot bool `json:"allow_resize_without_reboot"`
+	// Whether the VM can be moved to another hypervisor while booted
+	AllowedHotMigrate bool `json:"allowed_hot_migrate"`
+	// Suspended VMs are stopped and can't be booted until unsuspended
+	Suspended bool `json:"suspended"`
 }
 
 // IP address of a virtual machine as represented by /virtual_machines/:id.json
+// and of a network's pool as represented by /settings/networks/:id/ip_addresses.json
 type IpAddress struct {
+	Id             int    `json:"id"`
 	Address        string `json:"address"`
 	Gateway        string `json:"gateway"`
 	Broadcast      string `json:"broadcast"`
 	NetworkAddress string `json:"network_address"`
 	Netmask        string `json:"netmask"`
+	NetworkId      int    `json:"network_id"`
+	Free           bool   `json:"free"`
 }
 
 // Remote Access Session
@@ -49,7 +63,11 @@ type RemoteAccessSession struct {
 }
 
 func (c *Client) GetRemoteAccessSession(id int) (RemoteAccessSession, error) {
-	data, err, _ := c.getReq("/virtual_machines/", strconv.Itoa(id), "/console.json")
+	return c.GetRemoteAccessSessionContext(context.Background(), id)
+}
+
+func (c *Client) GetRemoteAccessSessionContext(ctx context.Context, id int) (RemoteAccessSession, error) {
+	data, err, _ := c.getReq(ctx, "/virtual_machines/", strconv.Itoa(id), "/console.json")
 	if err != nil {
 		return RemoteAccessSession{}, err
 	}
@@ -62,9 +80,76 @@ func (c *Client) GetRemoteAccessSession(id int) (RemoteAccessSession, error) {
 	}
 }
 
-// Fetches a list of Virtual Machines from the dashboard server
+// Fetches every Virtual Machine from the dashboard server, walking all pages
 func (c *Client) GetVirtualMachines() (VirtualMachines, error) {
-	data, err, _ := c.getReq("virtual_machines.json")
+	return c.GetVirtualMachinesContext(context.Background())
+}
+
+// Fetches every Virtual Machine from the dashboard server, walking all pages, bound to ctx
+func (c *Client) GetVirtualMachinesContext(ctx context.Context) (VirtualMachines, error) {
+	return c.ListVirtualMachinesContext(ctx, ListOptions{}).All()
+}
+
+// Iterates over the pages of /virtual_machines.json, see ListVirtualMachines
+type VirtualMachinePager struct {
+	pager
+	page VirtualMachines
+}
+
+// Returns a pager over the Virtual Machines, starting at opts.Page. Use it as:
+//
+//	p := c.ListVirtualMachines(onapp.ListOptions{PerPage: 50})
+//	for p.Next() {
+//	    for _, vm := range p.Page() { ... }
+//	}
+//	if err := p.Err(); err != nil { ... }
+func (c *Client) ListVirtualMachines(opts ListOptions) *VirtualMachinePager {
+	return c.ListVirtualMachinesContext(context.Background(), opts)
+}
+
+func (c *Client) ListVirtualMachinesContext(ctx context.Context, opts ListOptions) *VirtualMachinePager {
+	p := &VirtualMachinePager{}
+	p.pager = newPager(ctx, opts, func(ctx context.Context, opts ListOptions) (int, int, error) {
+		vms, err := c.GetVirtualMachinesPageContext(ctx, opts)
+		if err != nil {
+			return 0, 0, err
+		}
+		p.page = vms
+		if len(vms) == 0 {
+			return 0, 0, nil
+		}
+		return len(vms), vms[0].Id, nil
+	})
+	return p
+}
+
+// Fetches the next page, returning false when there are no more or an error occurred
+func (p *VirtualMachinePager) Next() bool {
+	p.page = nil
+	return p.next()
+}
+
+// The Virtual Machines on the current page
+func (p *VirtualMachinePager) Page() VirtualMachines {
+	return p.page
+}
+
+// Walks the remaining pages and returns their Virtual Machines together
+func (p *VirtualMachinePager) All() (VirtualMachines, error) {
+	vms := VirtualMachines{}
+	for p.Next() {
+		vms = append(vms, p.Page()...)
+	}
+	return vms, p.Err()
+}
+
+// Fetches a single page of Virtual Machines from the dashboard server
+func (c *Client) GetVirtualMachinesPage(opts ListOptions) (VirtualMachines, error) {
+	return c.GetVirtualMachinesPageContext(context.Background(), opts)
+}
+
+func (c *Client) GetVirtualMachinesPageContext(ctx context.Context, opts ListOptions) (VirtualMachines, error) {
+	data, err, _ := c.getReq(ctx, "virtual_machines.json", opts.query())
 	if err != nil {
 		return nil, err
 	}
@@ -83,7 +168,12 @@ func (c *Client) GetVirtualMachines() (VirtualMachines, error) {
 
 // Fetches an individual Virtual Machine from the dashboard server
 func (c *Client) GetVirtualMachine(id int) (VirtualMachine, error) {
-	data, err, _ := c.getReq("virtual_machines/", strconv.Itoa(id), ".json")
+	return c.GetVirtualMachineContext(context.Background(), id)
+}
+
+// Fetches an individual Virtual Machine from the dashboard server, bound to ctx
+func (c *Client) GetVirtualMachineContext(ctx context.Context, id int) (VirtualMachine, error) {
+	data, err, _ := c.getReq(ctx, "virtual_machines/", strconv.Itoa(id), ".json")
 	if err != nil {
 		return VirtualMachine{}, err
 	}
@@ -97,36 +187,259 @@ func (c *Client) GetVirtualMachine(id int) (VirtualMachine, error) {
 	return vm, nil
 }
 
-func (c *Client) VirtualMachineStartup(id int) error {
-	_, err, rc := c.postReq("", "virtual_machines/", strconv.Itoa(id), "/startup.json")
-	if rc == 422 {
-		return errors.New("HTTP 422 - VM can't currently be booted")
+// Changes to make to a virtual machine, see UpdateVirtualMachine.
+// Zero values are left unchanged, AdminNote is a pointer so that it can be cleared.
+type VirtualMachineEdit struct {
+	Label     string  `json:"label,omitempty"`
+	Hostname  string  `json:"hostname,omitempty"`
+	AdminNote *string `json:"admin_note,omitempty"`
+	Cpus      int     `json:"cpus,omitempty"`
+	CpuShares int     `json:"cpu_shares,omitempty"`
+	Memory    int     `json:"memory,omitempty"`
+	// Allows CPUs and memory to be changed on a booted VM that doesn't allow resize
+	// without reboot, in which case the dashboard will reboot it to apply the change.
+	AllowColdResize bool `json:"allow_cold_resize,omitempty"`
+}
+
+var ErrResizeRequiresReboot = errors.New("Resizing this virtual machine requires a reboot, allow a cold resize to continue")
+
+func (e *VirtualMachineEdit) resizes() bool {
+	return e.Cpus != 0 || e.CpuShares != 0 || e.Memory != 0
+}
+
+// Changes the label, hostname, admin note, CPUs, CPU shares and memory of a virtual machine.
+// A booted VM that can't be resized without a reboot is only resized if edit.AllowColdResize is set,
+// otherwise ErrResizeRequiresReboot is returned.
+func (c *Client) UpdateVirtualMachine(id int, edit VirtualMachineEdit) error {
+	return c.UpdateVirtualMachineContext(context.Background(), id, edit)
+}
+
+func (c *Client) UpdateVirtualMachineContext(ctx context.Context, id int, edit VirtualMachineEdit) error {
+	if edit.Label == "" && edit.Hostname == "" && edit.AdminNote == nil && !edit.resizes() {
+		return errors.New("Nothing to update")
+	}
+	if edit.Hostname != "" && !hostnamePattern.MatchString(edit.Hostname) {
+		return fmt.Errorf("'%s' isn't a valid hostname", edit.Hostname)
+	}
+	if edit.Cpus < 0 || edit.CpuShares < 0 || edit.CpuShares > 100 || edit.Memory < 0 {
+		return errors.New("CPUs and memory can't be negative and CPU shares must be between 1 and 100")
+	}
+	if edit.resizes() && !edit.AllowColdResize {
+		vm, err := c.GetVirtualMachineContext(ctx, id)
+		if err != nil {
+			return err
+		}
+		if vm.Booted && !vm.AllowResizeWithoutReboot {
+			return ErrResizeRequiresReboot
+		}
+	}
+	body, err := json.Marshal(map[string]VirtualMachineEdit{"virtual_machine": edit})
+	if err != nil {
+		return err
 	}
+	_, err, _ = c.putReq(ctx, string(body), "virtual_machines/", strconv.Itoa(id), ".json")
+	return err
+}
+
+// Options for DeleteVirtualMachine
+type VirtualMachineDeleteOptions struct {
+	// Destroys every backup of the VM's disks rather than keeping them
+	DestroyAllBackups bool
+	// Converts the most recent backup into a template before the VM is destroyed
+	ConvertLastBackup bool
+}
+
+func (o VirtualMachineDeleteOptions) query() string {
+	v := url.Values{}
+	v.Set("destroy_all_backups", boolParam(o.DestroyAllBackups))
+	v.Set("convert_last_backup", boolParam(o.ConvertLastBackup))
+	return "?" + v.Encode()
+}
+
+// Destroys a virtual machine along with its disks
+func (c *Client) DeleteVirtualMachine(id int, opts VirtualMachineDeleteOptions) error {
+	return c.DeleteVirtualMachineContext(context.Background(), id, opts)
+}
+
+func (c *Client) DeleteVirtualMachineContext(ctx context.Context, id int, opts VirtualMachineDeleteOptions) error {
+	_, err, _ := c.deleteReq(ctx, "virtual_machines/", strconv.Itoa(id), ".json", opts.query())
+	return err
+}
+
+func (c *Client) VirtualMachineStartup(id int) error {
+	return c.VirtualMachineStartupContext(context.Background(), id)
+}
+
+func (c *Client) VirtualMachineStartupContext(ctx context.Context, id int) error {
+	_, err, _ := c.postReq(ctx, "", "virtual_machines/", strconv.Itoa(id), "/startup.json")
 	return err
 }
 
 func (c *Client) VirtualMachineShutdown(id int) error {
-	_, err, rc := c.postReq("", "virtual_machines/", strconv.Itoa(id), "/shutdown.json")
-	if rc == 422 {
-		return errors.New("HTTP 422 - VM can't currently be shut down")
-	}
+	return c.VirtualMachineShutdownContext(context.Background(), id)
+}
+
+func (c *Client) VirtualMachineShutdownContext(ctx context.Context, id int) error {
+	_, err, _ := c.postReq(ctx, "", "virtual_machines/", strconv.Itoa(id), "/shutdown.json")
 	return err
 }
 
 func (c *Client) VirtualMachineReboot(id int) error {
-	_, err, rc := c.postReq("", "virtual_machines/", strconv.Itoa(id), "/reboot.json")
-	if rc == 422 {
-		return errors.New("HTTP 422 - VM can't currently be rebooted")
+	return c.VirtualMachineRebootContext(context.Background(), id)
+}
+
+func (c *Client) VirtualMachineRebootContext(ctx context.Context, id int) error {
+	_, err, _ := c.postReq(ctx, "", "virtual_machines/", strconv.Itoa(id), "/reboot.json")
+	return err
+}
+
+var (
+	ErrHotMigrateNotAllowed = errors.New("This virtual machine can't be migrated while booted, shut it down for a cold migration")
+	ErrColdMigrateBooted    = errors.New("Cold migration requires the virtual machine to be shut down")
+)
+
+// Moves a virtual machine to another hypervisor. A hot migration moves a
+// booted VM without downtime, a cold migration moves a VM that is shut down.
+// The returned transaction may not be valid (see Transaction.IsValid) if the
+// dashboard hasn't queued it yet.
+func (c *Client) MigrateVirtualMachine(id, destinationHV int, hot bool) (Transaction, error) {
+	return c.MigrateVirtualMachineContext(context.Background(), id, destinationHV, hot)
+}
+
+func (c *Client) MigrateVirtualMachineContext(ctx context.Context, id, destinationHV int, hot bool) (Transaction, error) {
+	vm, err := c.GetVirtualMachineContext(ctx, id)
+	if err != nil {
+		return Transaction{}, err
 	}
+	if hot && !vm.Booted {
+		return Transaction{}, errors.New("Hot migration requires the virtual machine to be booted")
+	}
+	if hot && !vm.AllowedHotMigrate {
+		return Transaction{}, ErrHotMigrateNotAllowed
+	}
+	if !hot && vm.Booted {
+		return Transaction{}, ErrColdMigrateBooted
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"virtual_machine": map[string]interface{}{
+			"destination":              destinationHV,
+			"cold_migrate_on_rollback": false,
+		},
+	})
+	if err != nil {
+		return Transaction{}, err
+	}
+	mark := c.markVmTransactions(ctx, id)
+	data, err, _ := c.postReq(ctx, string(body), "virtual_machines/", strconv.Itoa(id), "/migration.json")
+	if err != nil {
+		return Transaction{}, err
+	}
+	return c.findVmTransaction(ctx, id, MigrationAction(hot), mark, data)
+}
+
+// The action of the transaction that performs a hot or cold migration
+func MigrationAction(hot bool) string {
+	if hot {
+		return "hot_migrate"
+	}
+	return "cold_migrate"
+}
+
+// Resets the root password of a virtual machine, to the given password or to
+// a random one chosen by the dashboard if empty. The new password can be read
+// from the VM's RootPassword once the transaction completes.
+// The transaction may not be valid (see Transaction.IsValid) if the dashboard hadn't queued it yet.
+func (c *Client) ResetRootPassword(id int, password string) (Transaction, error) {
+	return c.ResetRootPasswordContext(context.Background(), id, password)
+}
+
+func (c *Client) ResetRootPasswordContext(ctx context.Context, id int, password string) (Transaction, error) {
+	var body string
+	if password != "" {
+		if !validRootPassword(password) {
+			return Transaction{}, errors.New("The root password must be at least 6 characters without whitespace")
+		}
+		data, err := json.Marshal(map[string]interface{}{
+			"virtual_machine": map[string]string{"initial_root_password": password},
+		})
+		if err != nil {
+			return Transaction{}, err
+		}
+		body = string(data)
+	}
+	mark := c.markVmTransactions(ctx, id)
+	data, err, _ := c.postReq(ctx, body, "virtual_machines/", strconv.Itoa(id), "/reset_password.json")
+	if err != nil {
+		return Transaction{}, err
+	}
+	return c.findVmTransaction(ctx, id, "reset_root_password", mark, data)
+}
+
+// Powers a virtual machine off immediately, without a graceful shutdown
+func (c *Client) VirtualMachineStop(id int) error {
+	return c.VirtualMachineStopContext(context.Background(), id)
+}
+
+func (c *Client) VirtualMachineStopContext(ctx context.Context, id int) error {
+	_, err, _ := c.postReq(ctx, "", "virtual_machines/", strconv.Itoa(id), "/stop.json")
+	return err
+}
+
+// Stops a virtual machine and prevents it from being booted until unsuspended
+func (c *Client) VirtualMachineSuspend(id int) error {
+	return c.VirtualMachineSuspendContext(context.Background(), id)
+}
+
+func (c *Client) VirtualMachineSuspendContext(ctx context.Context, id int) error {
+	_, err, _ := c.postReq(ctx, "", "virtual_machines/", strconv.Itoa(id), "/suspend.json")
+	return err
+}
+
+func (c *Client) VirtualMachineUnsuspend(id int) error {
+	return c.VirtualMachineUnsuspendContext(context.Background(), id)
+}
+
+func (c *Client) VirtualMachineUnsuspendContext(ctx context.Context, id int) error {
+	_, err, _ := c.postReq(ctx, "", "virtual_machines/", strconv.Itoa(id), "/unsuspend.json")
 	return err
 }
 
+// Boots a virtual machine into the recovery image, with its own disks attached
+func (c *Client) VirtualMachineStartupRecovery(id int) error {
+	return c.VirtualMachineStartupRecoveryContext(context.Background(), id)
+}
+
+func (c *Client) VirtualMachineStartupRecoveryContext(ctx context.Context, id int) error {
+	_, err, _ := c.postReq(ctx, "", "virtual_machines/", strconv.Itoa(id), "/startup.json?mode=recovery")
+	return err
+}
+
+// Reboots a virtual machine into the recovery image, with its own disks attached
+func (c *Client) VirtualMachineRebootRecovery(id int) error {
+	return c.VirtualMachineRebootRecoveryContext(context.Background(), id)
+}
+
+func (c *Client) VirtualMachineRebootRecoveryContext(ctx context.Context, id int) error {
+	_, err, _ := c.postReq(ctx, "", "virtual_machines/", strconv.Itoa(id), "/reboot.json?mode=recovery")
+	return err
+}
+
+// Fetches the most recent page of transactions on a virtual machine.
+// Use VirtualMachineListTransactions to walk further back.
 func (c *Client) VirtualMachineGetTransactions(vmId int) (Transactions, error) {
-	return c.getTransactions(vmId)
+	return c.VirtualMachineGetTransactionsContext(context.Background(), vmId)
+}
+
+func (c *Client) VirtualMachineGetTransactionsContext(ctx context.Context, vmId int) (Transactions, error) {
+	return c.getTransactions(ctx, vmId, ListOptions{})
 }
 
 func (c *Client) VirtualMachineGetLatestTransaction(vmId int, statuses ...string) (Transaction, error) {
-	txns, err := c.VirtualMachineGetTransactions(vmId)
+	return c.VirtualMachineGetLatestTransactionContext(context.Background(), vmId, statuses...)
+}
+
+func (c *Client) VirtualMachineGetLatestTransactionContext(ctx context.Context, vmId int, statuses ...string) (Transaction, error) {
+	txns, err := c.VirtualMachineGetTransactionsContext(ctx, vmId)
 	if err != nil {
 		return Transaction{}, err
 	}
@@ -149,22 +462,114 @@ func (vm *VirtualMachine) Startup() error {
 	return vm.client.VirtualMachineStartup(vm.Id)
 }
 
+func (vm *VirtualMachine) StartupContext(ctx context.Context) error {
+	return vm.client.VirtualMachineStartupContext(ctx, vm.Id)
+}
+
 func (vm *VirtualMachine) Shutdown() error {
 	return vm.client.VirtualMachineShutdown(vm.Id)
 }
 
+func (vm *VirtualMachine) ShutdownContext(ctx context.Context) error {
+	return vm.client.VirtualMachineShutdownContext(ctx, vm.Id)
+}
+
 func (vm *VirtualMachine) Reboot() error {
 	return vm.client.VirtualMachineReboot(vm.Id)
 }
 
+func (vm *VirtualMachine) RebootContext(ctx context.Context) error {
+	return vm.client.VirtualMachineRebootContext(ctx, vm.Id)
+}
+
+func (vm *VirtualMachine) Stop() error {
+	return vm.client.VirtualMachineStop(vm.Id)
+}
+
+func (vm *VirtualMachine) StopContext(ctx context.Context) error {
+	return vm.client.VirtualMachineStopContext(ctx, vm.Id)
+}
+
+func (vm *VirtualMachine) Suspend() error {
+	return vm.client.VirtualMachineSuspend(vm.Id)
+}
+
+func (vm *VirtualMachine) SuspendContext(ctx context.Context) error {
+	return vm.client.VirtualMachineSuspendContext(ctx, vm.Id)
+}
+
+func (vm *VirtualMachine) Unsuspend() error {
+	return vm.client.VirtualMachineUnsuspend(vm.Id)
+}
+
+func (vm *VirtualMachine) UnsuspendContext(ctx context.Context) error {
+	return vm.client.VirtualMachineUnsuspendContext(ctx, vm.Id)
+}
+
+func (vm *VirtualMachine) StartupRecovery() error {
+	return vm.client.VirtualMachineStartupRecovery(vm.Id)
+}
+
+func (vm *VirtualMachine) StartupRecoveryContext(ctx context.Context) error {
+	return vm.client.VirtualMachineStartupRecoveryContext(ctx, vm.Id)
+}
+
+func (vm *VirtualMachine) RebootRecovery() error {
+	return vm.client.VirtualMachineRebootRecovery(vm.Id)
+}
+
+func (vm *VirtualMachine) RebootRecoveryContext(ctx context.Context) error {
+	return vm.client.VirtualMachineRebootRecoveryContext(ctx, vm.Id)
+}
+
+func (vm *VirtualMachine) Update(edit VirtualMachineEdit) error {
+	return vm.client.UpdateVirtualMachine(vm.Id, edit)
+}
+
+func (vm *VirtualMachine) UpdateContext(ctx context.Context, edit VirtualMachineEdit) error {
+	return vm.client.UpdateVirtualMachineContext(ctx, vm.Id, edit)
+}
+
+func (vm *VirtualMachine) Delete(opts VirtualMachineDeleteOptions) error {
+	return vm.client.DeleteVirtualMachine(vm.Id, opts)
+}
+
+func (vm *VirtualMachine) DeleteContext(ctx context.Context, opts VirtualMachineDeleteOptions) error {
+	return vm.client.DeleteVirtualMachineContext(ctx, vm.Id, opts)
+}
+
+func (vm *VirtualMachine) Migrate(destinationHV int, hot bool) (Transaction, error) {
+	return vm.client.MigrateVirtualMachine(vm.Id, destinationHV, hot)
+}
+
+func (vm *VirtualMachine) MigrateContext(ctx context.Context, destinationHV int, hot bool) (Transaction, error) {
+	return vm.client.MigrateVirtualMachineContext(ctx, vm.Id, destinationHV, hot)
+}
+
+func (vm *VirtualMachine) ResetRootPassword(password string) (Transaction, error) {
+	return vm.client.ResetRootPassword(vm.Id, password)
+}
+
+func (vm *VirtualMachine) ResetRootPasswordContext(ctx context.Context, password string) (Transaction, error) {
+	return vm.client.ResetRootPasswordContext(ctx, vm.Id, password)
+}
+
 func (vm *VirtualMachine) GetTransactions() (Transactions, error) {
 	return vm.client.VirtualMachineGetTransactions(vm.Id)
 }
 
+func (vm *VirtualMachine) GetTransactionsContext(ctx context.Context) (Transactions, error) {
+	return vm.client.VirtualMachineGetTransactionsContext(ctx, vm.Id)
+}
+
 func (vm *VirtualMachine) GetRunningTransaction() (Transaction, error) {
 	return vm.client.VirtualMachineGetLatestTransaction(vm.Id, "running")
 }
 
+func (vm *VirtualMachine) GetRunningTransactionContext(ctx context.Context) (Transaction, error) {
+	return vm.client.VirtualMachineGetLatestTransactionContext(ctx, vm.Id, "running")
+}
+
 func (vm *VirtualMachine) GetIpAddresses() ([]IpAddress, error) {
 	var addrs []IpAddress
 	for _, v := range vm.IpAddressesRaw {
@@ -217,11 +622,15 @@ func (vm *VirtualMachine) BootedStringColored() string {
 }
 
 func (vm *VirtualMachine) GetRemoteAccessSession() (*RemoteAccessSession, error) {
-	ras, err := vm.client.GetRemoteAccessSession(vm.Id)
+	return vm.GetRemoteAccessSessionContext(context.Background())
+}
+
+func (vm *VirtualMachine) GetRemoteAccessSessionContext(ctx context.Context) (*RemoteAccessSession, error) {
+	ras, err := vm.client.GetRemoteAccessSessionContext(ctx, vm.Id)
 	if err != nil {
 		return &RemoteAccessSession{}, err
 	}
-	newVm, err := vm.client.GetVirtualMachine(vm.Id)
+	newVm, err := vm.client.GetVirtualMachineContext(ctx, vm.Id)
 	if err != nil {
 		return &RemoteAccessSession{}, err
 	}
@@ -0,0 +1,163 @@
+package onapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Parameters for building a new virtual machine, see CreateVirtualMachine.
+// Memory is in MB and disk sizes in GB. Either HypervisorId or HypervisorZoneId
+// may be left as zero to let the dashboard choose. Unless RequiredIpAssignment
+// is set, the VM is built without an IP address.
+type VirtualMachineBuild struct {
+	TemplateId       int    `json:"template_id"`
+	HypervisorId     int    `json:"hypervisor_id,omitempty"`
+	HypervisorZoneId int    `json:"hypervisor_group_id,omitempty"`
+	Cpus             int    `json:"cpus"`
+	CpuShares        int    `json:"cpu_shares,omitempty"`
+	Memory           int    `json:"memory"`
+	PrimaryDiskSize  int    `json:"primary_disk_size"`
+	SwapDiskSize     int    `json:"swap_disk_size"`
+	PrimaryNetworkId int    `json:"primary_network_id,omitempty"`
+	Hostname         string `json:"hostname"`
+	Label            string `json:"label"`
+	RootPassword     string `json:"initial_root_password,omitempty"`
+	RequiredStartup  bool   `json:"required_virtual_machine_startup"`
+	// Assigns an IP address from the primary network while building
+	RequiredIpAssignment bool `json:"required_ip_address_assignment"`
+}
+
+var hostnamePattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)*$`)
+
+func validRootPassword(p string) bool {
+	return len(p) >= 6 && !strings.ContainsAny(p, " \t\r\n")
+}
+
+// Checks the build parameters before they're sent to the dashboard server,
+// returning an error describing every problem found.
+func (b *VirtualMachineBuild) Validate() error {
+	var problems []string
+	if b.TemplateId <= 0 {
+		problems = append(problems, "a template is required")
+	}
+	if b.HypervisorId < 0 || b.HypervisorZoneId < 0 || b.PrimaryNetworkId < 0 {
+		problems = append(problems, "ids can't be negative")
+	}
+	if strings.TrimSpace(b.Label) == "" {
+		problems = append(problems, "a label is required")
+	}
+	if !hostnamePattern.MatchString(b.Hostname) {
+		problems = append(problems, fmt.Sprintf("'%s' isn't a valid hostname", b.Hostname))
+	}
+	if b.Cpus < 1 {
+		problems = append(problems, "at least 1 CPU is required")
+	}
+	if b.CpuShares < 1 || b.CpuShares > 100 {
+		problems = append(problems, "CPU shares must be between 1 and 100")
+	}
+	if b.Memory < 128 {
+		problems = append(problems, "at least 128MB of memory is required")
+	}
+	if b.PrimaryDiskSize < 1 {
+		problems = append(problems, "the primary disk must be at least 1GB")
+	}
+	if b.SwapDiskSize < 0 {
+		problems = append(problems, "the swap disk size can't be negative")
+	}
+	if b.RootPassword != "" && !validRootPassword(b.RootPassword) {
+		problems = append(problems, "the root password must be at least 6 characters without whitespace")
+	}
+	if len(problems) > 0 {
+		return errors.New("Invalid virtual machine build: " + strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// Builds a new virtual machine, returning it along with its build transaction.
+// Once the VM has been created no error is returned, so that callers don't retry and
+// build a duplicate. Finding the transaction is best-effort: it may not be valid
+// (see Transaction.IsValid) if the dashboard hadn't queued it yet or couldn't be asked.
+func (c *Client) CreateVirtualMachine(b VirtualMachineBuild) (VirtualMachine, Transaction, error) {
+	return c.CreateVirtualMachineContext(context.Background(), b)
+}
+
+func (c *Client) CreateVirtualMachineContext(ctx context.Context, b VirtualMachineBuild) (VirtualMachine, Transaction, error) {
+	if err := b.Validate(); err != nil {
+		return VirtualMachine{}, Transaction{}, err
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"virtual_machine": struct {
+			VirtualMachineBuild
+			RequiredBuild bool `json:"required_virtual_machine_build"`
+		}{b, true},
+	})
+	if err != nil {
+		return VirtualMachine{}, Transaction{}, err
+	}
+	data, err, _ := c.postReq(ctx, string(body), "virtual_machines.json")
+	if err != nil {
+		return VirtualMachine{}, Transaction{}, err
+	}
+	var out map[string]VirtualMachine
+	if err := json.Unmarshal(data, &out); err != nil {
+		return VirtualMachine{}, Transaction{}, err
+	}
+	vm := out["virtual_machine"]
+	vm.client = c
+	// A new VM has no earlier transactions to mistake for its build
+	tx, _ := c.findVmTransaction(ctx, vm.Id, "build_virtual_machine", vmTransactionMark{listed: true}, data)
+	return vm, tx, nil
+}
+
+// Parameters for rebuilding a virtual machine, see RebuildVirtualMachine.
+// The root password is left unchanged when empty.
+type VirtualMachineRebuild struct {
+	TemplateId      int    `json:"template_id"`
+	RequiredStartup bool   `json:"required_startup"`
+	RootPassword    string `json:"initial_root_password,omitempty"`
+}
+
+func (r *VirtualMachineRebuild) Validate() error {
+	if r.TemplateId <= 0 {
+		return errors.New("Invalid virtual machine rebuild: a template is required")
+	}
+	if r.RootPassword != "" && !validRootPassword(r.RootPassword) {
+		return errors.New("Invalid virtual machine rebuild: the root password must be at least 6 characters without whitespace")
+	}
+	return nil
+}
+
+// Reinstalls a virtual machine from a template, destroying the contents of its primary disk.
+// The transaction may not be valid (see Transaction.IsValid) if the dashboard hadn't queued it yet.
+func (c *Client) RebuildVirtualMachine(id int, r VirtualMachineRebuild) (Transaction, error) {
+	return c.RebuildVirtualMachineContext(context.Background(), id, r)
+}
+
+func (c *Client) RebuildVirtualMachineContext(ctx context.Context, id int, r VirtualMachineRebuild) (Transaction, error) {
+	if err := r.Validate(); err != nil {
+		return Transaction{}, err
+	}
+	body, err := json.Marshal(map[string]interface{}{"virtual_machine": r})
+	if err != nil {
+		return Transaction{}, err
+	}
+	mark := c.markVmTransactions(ctx, id)
+	data, err, _ := c.postReq(ctx, string(body), "virtual_machines/", strconv.Itoa(id), "/build.json")
+	if err != nil {
+		return Transaction{}, err
+	}
+	return c.findVmTransaction(ctx, id, "build_virtual_machine", mark, data)
+}
+
+func (vm *VirtualMachine) Rebuild(r VirtualMachineRebuild) (Transaction, error) {
+	return vm.client.RebuildVirtualMachine(vm.Id, r)
+}
+
+func (vm *VirtualMachine) RebuildContext(ctx context.Context, r VirtualMachineRebuild) (Transaction, error) {
+	return vm.client.RebuildVirtualMachineContext(ctx, vm.Id, r)
+}
@@ -0,0 +1,315 @@
+package onapp
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+)
+
+func validBuild() VirtualMachineBuild {
+	return VirtualMachineBuild{
+		TemplateId:      1,
+		Cpus:            1,
+		CpuShares:       100,
+		Memory:          512,
+		PrimaryDiskSize: 10,
+		SwapDiskSize:    1,
+		Hostname:        "web1.example.org",
+		Label:           "web1",
+
+		RequiredIpAssignment: true,
+	}
+}
+
+func TestVirtualMachineBuildValidate(t *testing.T) {
+	b := validBuild()
+	if err := b.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	bad := []func(*VirtualMachineBuild){
+		func(b *VirtualMachineBuild) { b.TemplateId = 0 },
+		func(b *VirtualMachineBuild) { b.Label = " " },
+		func(b *VirtualMachineBuild) { b.Hostname = "web_1" },
+		func(b *VirtualMachineBuild) { b.Hostname = "-web" },
+		func(b *VirtualMachineBuild) { b.Cpus = 0 },
+		func(b *VirtualMachineBuild) { b.CpuShares = 0 },
+		func(b *VirtualMachineBuild) { b.CpuShares = 101 },
+		func(b *VirtualMachineBuild) { b.Memory = 64 },
+		func(b *VirtualMachineBuild) { b.PrimaryDiskSize = 0 },
+		func(b *VirtualMachineBuild) { b.RootPassword = "abc" },
+	}
+	for i, f := range bad {
+		b := validBuild()
+		f(&b)
+		if err := b.Validate(); err == nil {
+			t.Errorf("Case %d should have failed validation: %+v", i, b)
+		}
+	}
+}
+
+func TestCreateVirtualMachine(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/virtual_machines.json":
+			var body map[string]map[string]interface{}
+			data, _ := ioutil.ReadAll(r.Body)
+			if err := json.Unmarshal(data, &body); err != nil {
+				t.Error(err)
+				return
+			}
+			vm := body["virtual_machine"]
+			if vm["label"] != "web1" || vm["required_virtual_machine_build"] != true || vm["required_ip_address_assignment"] != true {
+				t.Errorf("Unexpected body: %s", data)
+			}
+			if _, ok := vm["hypervisor_id"]; ok {
+				t.Errorf("hypervisor_id should have been omitted: %s", data)
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"virtual_machine":{"id":7,"label":"web1"}}`))
+		case "/virtual_machines/7/transactions.json":
+			w.Write([]byte(`[{"transaction":{"id":99,"action":"build_virtual_machine","status":"pending"}}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c, _ := NewClient(ts.URL, "user@example.org", "1234")
+	vm, tx, err := c.CreateVirtualMachine(validBuild())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vm.Id != 7 || tx.Id != 99 {
+		t.Errorf("Unexpected result: vm %d, tx %d", vm.Id, tx.Id)
+	}
+}
+
+func TestCreateVirtualMachineWithoutTransaction(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/virtual_machines.json" {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"virtual_machine":{"id":7,"label":"web1"}}`))
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	// The VM exists even though its transaction couldn't be looked up,
+	// so an error would invite a retry that builds a duplicate
+	c, _ := NewClient(ts.URL, "user@example.org", "1234", WithRetryPolicy(RetryPolicy{}))
+	vm, tx, err := c.CreateVirtualMachine(validBuild())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vm.Id != 7 || tx.IsValid() {
+		t.Errorf("Unexpected result: vm %d, tx %d", vm.Id, tx.Id)
+	}
+}
+
+func TestUpdateVirtualMachineResizeGuard(t *testing.T) {
+	var puts int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			w.Write([]byte(`{"virtual_machine":{"id":7,"booted":true,"allow_resize_without_reboot":false}}`))
+		case "PUT":
+			puts++
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	c, _ := NewClient(ts.URL, "user@example.org", "1234")
+	if err := c.UpdateVirtualMachine(7, VirtualMachineEdit{Memory: 1024}); err != ErrResizeRequiresReboot {
+		t.Errorf("Expected ErrResizeRequiresReboot, got %v", err)
+	}
+	if err := c.UpdateVirtualMachine(7, VirtualMachineEdit{Memory: 1024, AllowColdResize: true}); err != nil {
+		t.Error(err)
+	}
+	if err := c.UpdateVirtualMachine(7, VirtualMachineEdit{Label: "web2"}); err != nil {
+		t.Error(err)
+	}
+	if puts != 2 {
+		t.Errorf("Expected 2 PUT requests, got %d", puts)
+	}
+}
+
+func TestDeleteVirtualMachine(t *testing.T) {
+	var calls int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Method != "DELETE" || r.URL.Path != "/virtual_machines/7.json" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		q := r.URL.Query()
+		if q.Get("destroy_all_backups") != "1" || q.Get("convert_last_backup") != "0" {
+			t.Errorf("Unexpected query: %s", r.URL.RawQuery)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, _ := NewClient(ts.URL, "user@example.org", "1234")
+	if err := c.DeleteVirtualMachine(7, VirtualMachineDeleteOptions{DestroyAllBackups: true}); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected a single request, got %d", calls)
+	}
+}
+
+func TestMigrateVirtualMachine(t *testing.T) {
+	ts, reqs := newAPIServer(t, map[string]string{
+		"GET /virtual_machines/7.json":              `{"virtual_machine":{"id":7,"booted":true,"allowed_hot_migrate":true}}`,
+		"GET /virtual_machines/7/transactions.json": queuedTransactions("hot_migrate"),
+		"POST /virtual_machines/7/migration.json":   `201 `,
+		"GET /virtual_machines/8.json":              `{"virtual_machine":{"id":8,"booted":true,"allowed_hot_migrate":false}}`,
+	})
+	defer ts.Close()
+	c := newAPIClient(ts)
+
+	tx, err := c.MigrateVirtualMachine(7, 3, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Id != 12 {
+		t.Errorf("Expected transaction #12, got #%d", tx.Id)
+	}
+	post := (*reqs)[2]
+	body, _ := post.Body["virtual_machine"].(map[string]interface{})
+	if post.Method != "POST" || body["destination"] != float64(3) || body["cold_migrate_on_rollback"] != false {
+		t.Errorf("Unexpected request: %+v", post)
+	}
+
+	if _, err := c.MigrateVirtualMachine(7, 3, false); err != ErrColdMigrateBooted {
+		t.Errorf("Expected ErrColdMigrateBooted, got %v", err)
+	}
+	if _, err := c.MigrateVirtualMachine(8, 3, true); err != ErrHotMigrateNotAllowed {
+		t.Errorf("Expected ErrHotMigrateNotAllowed, got %v", err)
+	}
+}
+
+func TestMigrateVirtualMachineNotQueued(t *testing.T) {
+	ts, _ := newAPIServer(t, map[string]string{
+		"GET /virtual_machines/7.json":              `{"virtual_machine":{"id":7,"booted":false}}`,
+		"GET /virtual_machines/7/transactions.json": `[{"transaction":{"id":10,"action":"cold_migrate"}}]`,
+		"POST /virtual_machines/7/migration.json":   `201 `,
+	})
+	defer ts.Close()
+
+	tx, err := newAPIClient(ts).MigrateVirtualMachine(7, 3, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.IsValid() {
+		t.Errorf("Expected no transaction, got the stale #%d", tx.Id)
+	}
+}
+
+func TestRebuildVirtualMachine(t *testing.T) {
+	ts, reqs := newAPIServer(t, map[string]string{
+		"GET /virtual_machines/7/transactions.json": queuedTransactions("build_virtual_machine"),
+		"POST /virtual_machines/7/build.json":       `201 `,
+	})
+	defer ts.Close()
+	c := newAPIClient(ts)
+
+	for _, r := range []VirtualMachineRebuild{{}, {TemplateId: 2, RootPassword: "short"}, {TemplateId: 2, RootPassword: "has space"}} {
+		if _, err := c.RebuildVirtualMachine(7, r); err == nil {
+			t.Errorf("Expected %+v to be rejected", r)
+		}
+	}
+	if len(*reqs) != 0 {
+		t.Fatalf("Invalid rebuilds were sent: %v", *reqs)
+	}
+
+	tx, err := c.RebuildVirtualMachine(7, VirtualMachineRebuild{TemplateId: 2, RequiredStartup: true, RootPassword: "s3cret!"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Id != 12 {
+		t.Errorf("Expected transaction #12, got #%d", tx.Id)
+	}
+	body, _ := (*reqs)[1].Body["virtual_machine"].(map[string]interface{})
+	if body["template_id"] != float64(2) || body["required_startup"] != true || body["initial_root_password"] != "s3cret!" {
+		t.Errorf("Unexpected body: %v", (*reqs)[1].Body)
+	}
+
+	// The password is left alone when it isn't given
+	*reqs = nil
+	if _, err := c.RebuildVirtualMachine(7, VirtualMachineRebuild{TemplateId: 2}); err != nil {
+		t.Fatal(err)
+	}
+	body, _ = (*reqs)[1].Body["virtual_machine"].(map[string]interface{})
+	if _, ok := body["initial_root_password"]; ok {
+		t.Errorf("Unexpected password in body: %v", (*reqs)[1].Body)
+	}
+}
+
+func TestResetRootPassword(t *testing.T) {
+	ts, reqs := newAPIServer(t, map[string]string{
+		"GET /virtual_machines/7/transactions.json":    queuedTransactions("reset_root_password"),
+		"POST /virtual_machines/7/reset_password.json": `201 `,
+	})
+	defer ts.Close()
+	c := newAPIClient(ts)
+
+	if _, err := c.ResetRootPassword(7, "has space"); err == nil {
+		t.Error("Expected an invalid password to be rejected")
+	}
+	tx, err := c.ResetRootPassword(7, "s3cret!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Id != 12 {
+		t.Errorf("Expected transaction #12, got #%d", tx.Id)
+	}
+	body, _ := (*reqs)[1].Body["virtual_machine"].(map[string]interface{})
+	if body["initial_root_password"] != "s3cret!" {
+		t.Errorf("Unexpected body: %v", (*reqs)[1].Body)
+	}
+
+	// The dashboard picks a password when there isn't one
+	*reqs = nil
+	if _, err := c.ResetRootPassword(7, ""); err != nil {
+		t.Fatal(err)
+	}
+	if (*reqs)[1].Body != nil {
+		t.Errorf("Expected no body, got %v", (*reqs)[1].Body)
+	}
+}
+
+func TestVirtualMachinePowerActions(t *testing.T) {
+	ts, reqs := newAPIServer(t, map[string]string{
+		"GET /virtual_machines/7.json":            `{"virtual_machine":{"id":7,"booted":true,"suspended":true}}`,
+		"POST /virtual_machines/7/stop.json":      `201 `,
+		"POST /virtual_machines/7/suspend.json":   `201 `,
+		"POST /virtual_machines/7/unsuspend.json": `201 `,
+		"POST /virtual_machines/7/startup.json":   `201 `,
+		"POST /virtual_machines/7/reboot.json":    `201 `,
+	})
+	defer ts.Close()
+
+	vm, err := newAPIClient(ts).GetVirtualMachine(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !vm.Suspended {
+		t.Error("Expected the VM to be suspended")
+	}
+	for _, action := range []func() error{vm.Stop, vm.Suspend, vm.Unsuspend, vm.StartupRecovery, vm.RebootRecovery} {
+		if err := action(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []string{"stop.json ", "suspend.json ", "unsuspend.json ", "startup.json recovery", "reboot.json recovery"}
+	for i, r := range (*reqs)[1:] {
+		if got := path.Base(r.Path) + " " + r.Query.Get("mode"); r.Method != "POST" || got != expected[i] {
+			t.Errorf("Request %d: expected POST %s, got %s %s", i+1, expected[i], r.Method, got)
+		}
+	}
+}